@@ -0,0 +1,113 @@
+package weave
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Given an array of an arbitrary struct `st` and the *ordered* columns to
+// include, returns a string containing the delimited (ex: TSV, or
+// semicolon-separated for locales that use the comma as a decimal separator)
+// representation of the data contained therein.
+// Identical to ToCSV, except fields are separated by the given delimiter;
+// fields are quoted if they contain the delimiter (rather than a comma).
+// The quote character, line terminator, and byte order mark are set via Quote,
+// CRLF, and BOM.
+//
+// Returns ErrInvalidDelimiter if the delimiter or quote character is a line
+// break, invalid, or the two are equal.
+//
+// ! Returns the empty string (and no error) if columns or st are empty
+func ToDelimited[Any any, C Columns](st []Any, columns C, delimiter rune, opts ...Option) (string, error) {
+	if st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
+		return "", nil
+	}
+
+	var out strings.Builder
+	enc := NewDelimitedEncoder(&out, columns, delimiter, opts...)
+	for i, s := range st { // operate on each struct
+		if err := enc.Encode(s); err != nil {
+			return "", fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(out.String(), enc.terminator), nil
+}
+
+// NewDelimitedEncoder returns an encoder that writes the given, *ordered*,
+// fully-qualified columns of each record to w, with fields separated by the
+// given delimiter.
+// See ToDelimited.
+//
+// An invalid delimiter or quote character causes Encode and Flush to return
+// ErrInvalidDelimiter.
+func NewDelimitedEncoder[C Columns](w io.Writer, columns C, delimiter rune, opts ...Option) *CSVEncoder {
+	o := newOptions(opts)
+	return &CSVEncoder{
+		w:        bufio.NewWriter(w),
+		resolver: resolver{columns: toColumns(columns), opts: o},
+		dialect:  newDialect(delimiter, o),
+	}
+}
+
+// dialect is the set of characters used to delimit and quote a delimited
+// output.
+type dialect struct {
+	delimiter  rune
+	quote      rune
+	terminator string // line terminator
+	bom        bool   // prefix the output with a UTF-8 byte order mark
+	specials   string // characters requiring a field be quoted
+}
+
+// newDialect returns the dialect built from the given delimiter and options.
+func newDialect(delimiter rune, o *options) dialect {
+	d := dialect{delimiter: delimiter, quote: o.quote, terminator: "\n", bom: o.bom}
+	if o.crlf {
+		d.terminator = "\r\n"
+	}
+	d.specials = string([]rune{d.delimiter, d.quote, '\r', '\n'})
+	return d
+}
+
+// validate returns ErrInvalidDelimiter if the dialect cannot be unambiguously
+// read back.
+func (d *dialect) validate() error {
+	for _, r := range []rune{d.delimiter, d.quote} {
+		if r == '\r' || r == '\n' || r == utf8.RuneError || !utf8.ValidRune(r) {
+			return fmt.Errorf("%w: %q", ErrInvalidDelimiter, r)
+		}
+	}
+	if d.delimiter == d.quote {
+		return fmt.Errorf("%w: delimiter and quote are both %q", ErrInvalidDelimiter, d.delimiter)
+	}
+	return nil
+}
+
+// escape returns the given field (or header) quoted and escaped per RFC 4180,
+// generalized to the dialect's delimiter and quote character.
+// A field is enclosed in quotes if it contains the delimiter, the quote
+// character, or a line break (CR or LF). Fields with leading or trailing
+// whitespace are also quoted so readers that trim unquoted fields do not alter
+// them.
+// Quotes within an enclosed field are escaped by doubling them.
+// All other fields are returned unaltered.
+func (d *dialect) escape(field string) string {
+	if field == "" {
+		return field
+	}
+	first, _ := utf8.DecodeRuneInString(field)
+	last, _ := utf8.DecodeLastRuneInString(field)
+	if !strings.ContainsAny(field, d.specials) && !unicode.IsSpace(first) && !unicode.IsSpace(last) {
+		return field
+	}
+	q := string(d.quote)
+	return q + strings.ReplaceAll(field, q, q+q) + q
+}
@@ -0,0 +1,133 @@
+package weave
+
+import (
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToDelimited(t *testing.T) {
+	type rec struct {
+		Name string
+		Note string
+		N    float64
+	}
+	data := []rec{
+		{Name: "a", Note: "tab\there", N: 1.5},
+		{Name: "b;c", Note: `say "hi", 'bye'`, N: 2},
+	}
+	columns := []string{"Name", "Note", "N"}
+
+	t.Run("superfluous", func(t *testing.T) {
+		actual, err := ToDelimited[any](nil, columns, '\t')
+		if err != nil {
+			t.Error("Expected no error, got: ", err)
+		}
+		if actual != "" {
+			t.Errorf("string mismatch.\nactual%s\nexpected the empty string", actual)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		delimiter rune
+		opts      []Option
+		want      string
+	}{
+		{
+			name:      "TSV",
+			delimiter: '\t',
+			want:      "Name\tNote\tN\na\t\"tab\there\"\t1.5\nb;c\t\"say \"\"hi\"\", 'bye'\"\t2",
+		},
+		{
+			name:      "semicolon",
+			delimiter: ';',
+			want:      "Name;Note;N\na;tab\there;1.5\n\"b;c\";\"say \"\"hi\"\", 'bye'\";2",
+		},
+		{
+			name:      "single quote",
+			delimiter: ';',
+			opts:      []Option{Quote('\'')},
+			want:      "Name;Note;N\na;tab\there;1.5\n'b;c';'say \"hi\", ''bye''';2",
+		},
+		{
+			name:      "CRLF",
+			delimiter: ',',
+			opts:      []Option{CRLF()},
+			want:      "Name,Note,N\r\na,tab\there,1.5\r\nb;c,\"say \"\"hi\"\", 'bye'\",2",
+		},
+		{
+			name:      "BOM",
+			delimiter: '\t',
+			opts:      []Option{BOM()},
+			want:      "\uFEFFName\tNote\tN\na\t\"tab\there\"\t1.5\nb;c\t\"say \"\"hi\"\", 'bye'\"\t2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ToDelimited(data, columns, tt.delimiter, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.want {
+				t.Errorf("\n---ToDelimited()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+			}
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		for _, delimiter := range []rune{',', '\t', ';', '|'} {
+			actual, err := ToDelimited(data, columns, delimiter, CRLF())
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := csv.NewReader(strings.NewReader(actual))
+			r.Comma = delimiter
+			records, err := r.ReadAll()
+			if err != nil {
+				t.Fatalf("%q: %v", delimiter, err)
+			}
+			want := [][]string{columns, {"a", "tab\there", "1.5"}, {"b;c", `say "hi", 'bye'`, "2"}}
+			if !reflect.DeepEqual(records, want) {
+				t.Errorf("%q: want <> actual:\nwant: '%v'\nactual: '%v'\n", delimiter, want, records)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, tt := range []struct {
+			delimiter rune
+			opts      []Option
+		}{
+			{delimiter: '\n'},
+			{delimiter: '\r'},
+			{delimiter: -1},
+			{delimiter: '"'},
+			{delimiter: ',', opts: []Option{Quote('\n')}},
+			{delimiter: ';', opts: []Option{Quote(';')}},
+		} {
+			if _, err := ToDelimited(data, columns, tt.delimiter, tt.opts...); !errors.Is(err, ErrInvalidDelimiter) {
+				t.Errorf("%q: expected '%v', got '%v'", tt.delimiter, ErrInvalidDelimiter, err)
+			}
+		}
+	})
+
+	t.Run("encoder", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewDelimitedEncoder(&sb, columns, '\t', CRLF(), BOM())
+		for _, d := range data {
+			if err := enc.Encode(d); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		want := "\uFEFFName\tNote\tN\r\na\t\"tab\there\"\t1.5\r\nb;c\t\"say \"\"hi\"\", 'bye'\"\t2\r\n"
+		if sb.String() != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, sb.String())
+		}
+	})
+}
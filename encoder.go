@@ -0,0 +1,399 @@
+package weave
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// recordValue is the single validation path for records given to the output
+// modules and encoders.
+// Returns the struct value of the given record, dereferencing pointers (at any
+// depth) and interfaces.
+//
+// Returns an invalid Value (and no error) if the record is nil or a nil
+// pointer to a struct; callers decide whether to skip or represent it.
+// Returns ErrNotAStruct if the record is not a struct or pointer to a struct.
+func recordValue(record any) (reflect.Value, error) {
+	v := reflect.ValueOf(record)
+	if !v.IsValid() { // untyped nil
+		return v, nil
+	}
+	if t := derefType(v.Type()); t.Kind() != reflect.Struct {
+		return reflect.Value{}, ErrNotAStruct
+	}
+	v, _ = indirect(v) // invalid if nil
+	return v, nil
+}
+
+// derefType returns the type pointed to by t, at any depth of indirection.
+// A recursive pointer type (ex: `type P *P`) is returned as is.
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer && t.Elem() != t {
+		t = t.Elem()
+	}
+	return t
+}
+
+// recordsType returns the struct type of the first record in st that is not an
+// untyped nil. Typed nil pointers carry their type, so still count.
+//
+// Returns ErrStructIsNil if st has no typed records or ErrNotAStruct if the
+// record is not a struct or pointer to a struct.
+func recordsType[Any any](st []Any) (reflect.Type, error) {
+	for _, s := range st {
+		t := reflect.TypeOf(s)
+		if t == nil {
+			continue
+		}
+		if t = derefType(t); t.Kind() != reflect.Struct {
+			return nil, ErrNotAStruct
+		}
+		return t, nil
+	}
+	return nil, ErrStructIsNil
+}
+
+// resolver lazily resolves the columns of an encoder against the first record
+// it is given and ensures all later records are of the same type.
+// Records may be structs or pointers to structs, freely mixed.
+//
+// If the Heterogeneous option is given, records may instead be of any struct
+// type; columns are resolved once per type.
+//
+// Resolved columns are compiled into a schema and cached across resolvers
+// (see compileSchema).
+type resolver struct {
+	columns    []Column
+	opts       *options
+	*schema                 // of the current record's type; nil until the first
+	recordType reflect.Type // struct type of the current record; nil until the first
+	// heterogeneous only; schemas by struct type
+	schemas map[reflect.Type]*schema
+}
+
+// resolve validates the given record, resolving the columns if it is the first
+// non-nil record (or, if heterogeneous, the first of its type).
+// Returns the record's struct value, which is invalid if the record is nil.
+func (r *resolver) resolve(record any) (reflect.Value, error) {
+	v, err := recordValue(record)
+	if err != nil || !v.IsValid() {
+		return v, err
+	}
+	rt := v.Type()
+	if r.opts.heterogeneous {
+		return v, r.resolveType(rt)
+	}
+	if r.recordType == nil { // first record; resolve columns
+		s, err := compileSchema(rt, r.columns, r.opts)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		r.recordType = rt
+		r.schema = s.withFormatters(r.columns, r.opts.columnFormatters)
+	} else if rt != r.recordType {
+		return reflect.Value{}, fmt.Errorf("%w: %v is not %v", ErrMismatchedRecord, rt, r.recordType)
+	}
+	return v, nil
+}
+
+// resolveType sets the current schema to that of the given struct type,
+// resolving it if this is the first record of the type.
+// As a type is expected to lack some columns, columns are resolved leniently,
+// regardless of Strict.
+func (r *resolver) resolveType(rt reflect.Type) error {
+	if rt == r.recordType { // same as prior record
+		return nil
+	}
+	s, found := r.schemas[rt]
+	if !found {
+		lenient := *r.opts
+		lenient.strict = false
+		var err error
+		if s, err = compileSchema(rt, r.columns, &lenient); err != nil {
+			return fmt.Errorf("%v: %w", rt, err)
+		}
+		s = s.withFormatters(r.columns, r.opts.columnFormatters)
+		if r.schemas == nil {
+			r.schemas = make(map[reflect.Type]*schema)
+		}
+		r.schemas[rt] = s
+	}
+	r.recordType = rt
+	r.schema = s
+	return nil
+}
+
+// noExplosion is the result of elements when not exploding.
+// ! never modified
+var noExplosion = []reflect.Value{{}}
+
+// elements returns the element of the exploded slice to output in each row
+// for the given struct value; a single invalid element if not exploding.
+//
+// An empty (or nil) slice results in no rows or, if left joining, a single row
+// with an invalid element.
+func (r *resolver) elements(structVals reflect.Value) []reflect.Value {
+	if r.opts.explode == "" {
+		return noExplosion
+	}
+	var elems []reflect.Value
+	if r.explode != nil {
+		if data, ok := fieldValue(structVals, r.explode); ok {
+			if data, ok = indirect(data); ok {
+				elems = make([]reflect.Value, data.Len())
+				for i := range elems {
+					elems[i] = data.Index(i)
+				}
+			}
+		}
+	}
+	if len(elems) == 0 && r.opts.explodeLeft {
+		return noExplosion
+	}
+	return elems
+}
+
+// source returns the value the given column is resolved against: the exploded
+// element if the column is beneath the exploded slice, otherwise the struct.
+func source(structVals, elem reflect.Value, cf *columnField) reflect.Value {
+	if cf.exploded {
+		return elem
+	}
+	return structVals
+}
+
+//#region CSV
+
+// CSVEncoder writes records to an underlying io.Writer as CSV (or another
+// delimited format; see NewDelimitedEncoder), one row at a time, so the memory
+// used is independent of the number of records encoded.
+//
+// Columns are resolved against the first record given to Encode; every later
+// record must be of the same type, unless Heterogeneous is given. Records may
+// be structs or pointers to structs; nil records are output as a row of null
+// values (see NullAs) unless SkipNilRecords is given.
+// If Explode is given, each record is output as one row per element.
+// Output is buffered; call Flush once all records have been encoded.
+type CSVEncoder struct {
+	resolver
+	dialect
+	w           *bufio.Writer
+	wroteHeader bool
+}
+
+// NewCSVEncoder returns an encoder that writes the given, *ordered*,
+// fully-qualified columns of each record to w.
+// Columns may be given as qualified names or as Column specs.
+func NewCSVEncoder[C Columns](w io.Writer, columns C, opts ...Option) *CSVEncoder {
+	return NewDelimitedEncoder(w, columns, ',', opts...)
+}
+
+// Encode writes the CSV row for the given record, preceded by the header if
+// this is the first record encoded.
+//
+// The first record is used to resolve the columns and must be a struct.
+// Returns an error if the record is not of the same type as the first (unless
+// Heterogeneous is given).
+func (e *CSVEncoder) Encode(record any) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	v, err := e.resolve(record)
+	if err != nil {
+		return err
+	}
+	if !v.IsValid() && e.opts.skipNil {
+		return nil
+	}
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	if !v.IsValid() { // nil record
+		writeStructCSV(e.w, v, reflect.Value{}, &e.resolver, &e.dialect)
+		_, err = e.w.WriteString(e.terminator)
+		return err
+	}
+	for _, elem := range e.elements(v) {
+		writeStructCSV(e.w, v, elem, &e.resolver, &e.dialect)
+		if _, err = e.w.WriteString(e.terminator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+// If no records were encoded, the header is written first.
+func (e *CSVEncoder) Flush() error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// writeHeader writes the header line (preceded by the byte order mark, if
+// requested), if it has not already been written.
+func (e *CSVEncoder) writeHeader() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+	if e.bom {
+		e.w.WriteRune('\uFEFF')
+	}
+	for i, col := range e.columns {
+		if i > 0 {
+			e.w.WriteRune(e.delimiter)
+		}
+		e.w.WriteString(e.escape(col.Name()))
+	}
+	_, err := e.w.WriteString(e.terminator)
+	return err
+}
+
+//#endregion CSV
+
+//#region JSON
+
+// JSONMode selects the framing a JSONEncoder uses between records.
+type JSONMode int
+
+const (
+	// A single, well-formed JSON array, identical to the output of ToJSON.
+	JSONArray JSONMode = iota
+	// Newline-delimited JSON (NDJSON/JSON Lines); one object per line.
+	JSONLines
+)
+
+// JSONEncoder writes records to an underlying io.Writer as JSON objects, one
+// record at a time, so the memory used is independent of the number of
+// records encoded.
+// Fields are typed identically to ToJSON.
+//
+// Columns are resolved against the first record given to Encode; every later
+// record must be of the same type, unless Heterogeneous is given. Records may
+// be structs or pointers to structs; nil records are output as JSON null unless SkipNilRecords is given.
+// Output is buffered; call Close once all records have been encoded.
+type JSONEncoder struct {
+	resolver
+	w       *bufio.Writer
+	mode    JSONMode
+	count   uint64 // objects (or nulls) written
+	started bool   // array has been opened
+	closed  bool
+	err     error // invalid columns (see jsonCollision); returned by Encode
+}
+
+// NewJSONEncoder returns an encoder that writes the given fully-qualified
+// columns of each record to w, framed according to mode.
+// Columns may be given as qualified names or as Column specs.
+//
+// Columns whose output paths collide cause Encode to return ErrColumnCollision.
+func NewJSONEncoder[C Columns](w io.Writer, columns C, mode JSONMode, opts ...Option) *JSONEncoder {
+	cols := toColumns(columns)
+	return &JSONEncoder{
+		w:        bufio.NewWriter(w),
+		mode:     mode,
+		resolver: resolver{columns: cols, opts: newOptions(opts)},
+		err:      jsonCollision(cols),
+	}
+}
+
+// Encode writes the JSON object for the given record.
+//
+// The first record is used to resolve the columns and must be a struct.
+// Returns an error if the record is not of the same type as the first (unless
+// Heterogeneous is given) or if the encoder has been closed.
+func (e *JSONEncoder) Encode(record any) error {
+	if e.closed {
+		return ErrEncoderClosed
+	}
+	if e.err != nil {
+		return e.err
+	}
+	v, err := e.resolve(record)
+	if err != nil {
+		return err
+	}
+	if !v.IsValid() { // nil record
+		if e.opts.skipNil {
+			return nil
+		}
+		return e.write("null")
+	}
+	for _, elem := range e.elements(v) {
+		g, err := structToJSON(v, elem, &e.resolver)
+		if err != nil {
+			return err
+		}
+		// marshal explicitly, as g.String() outputs null on failure (ex: a
+		// cyclic value within a slice)
+		obj, err := g.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if err := e.write(string(obj)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write writes the given JSON value, framed according to the encoder's mode.
+func (e *JSONEncoder) write(obj string) (err error) {
+	switch e.mode {
+	case JSONArray:
+		e.open()
+		if e.count > 0 {
+			e.w.WriteByte(',')
+		}
+		_, err = e.w.WriteString(obj)
+	case JSONLines:
+		e.w.WriteString(obj)
+		err = e.w.WriteByte('\n')
+	default:
+		return fmt.Errorf("unknown JSON mode %d", e.mode)
+	}
+	e.count++
+
+	return err
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (e *JSONEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Close completes the output (closing the array, if applicable) and flushes
+// it to the underlying io.Writer.
+// It does *not* close the underlying io.Writer.
+//
+// An array encoder that was not given any records outputs an empty array.
+func (e *JSONEncoder) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.mode == JSONArray {
+		e.open()
+		e.w.WriteByte(']')
+	}
+	return e.w.Flush()
+}
+
+// open writes the opening bracket of the array, if it has not already been
+// written.
+func (e *JSONEncoder) open() {
+	if e.started {
+		return
+	}
+	e.started = true
+	e.w.WriteByte('[')
+}
+
+//#endregion JSON
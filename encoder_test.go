@@ -0,0 +1,313 @@
+package weave
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// countingWriter records the number of bytes written to it
+type countingWriter struct {
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += len(p)
+	return len(p), nil
+}
+
+func TestCSVEncoder(t *testing.T) {
+	type rec struct {
+		A    int
+		b    string
+		Nest struct {
+			C float64
+		}
+	}
+
+	t.Run("matches ToCSV", func(t *testing.T) {
+		data := []rec{{A: 1, b: "one"}, {A: 2, b: "two, too"}, {A: 3, b: `"three"`}}
+		data[2].Nest.C = 3.5
+		columns := []string{"A", "b", "Nest.C", "missing"}
+
+		var sb strings.Builder
+		enc := NewCSVEncoder(&sb, columns)
+		for _, d := range data {
+			if err := enc.Encode(d); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := ToCSV(data, columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += "\n"
+		if sb.String() != want {
+			t.Errorf("\n---CSVEncoder---\n'%v'\n---want---\n'%v'", sb.String(), want)
+		}
+	})
+
+	t.Run("no records", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewCSVEncoder(&sb, []string{"A", "b"})
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if want := "A,b\n"; sb.String() != want {
+			t.Errorf("expected only the header '%v', got '%v'", want, sb.String())
+		}
+	})
+
+	t.Run("aliased header", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewCSVEncoder(&sb, []Column{{Path: "A", Alias: "alpha"}, {Path: "Nest.C", Alias: "C"}})
+		enc.Encode(rec{A: 1})
+		enc.Flush()
+		if want := "alpha,C\n1,0\n"; sb.String() != want {
+			t.Errorf("\n---CSVEncoder---\n'%v'\n---want---\n'%v'", sb.String(), want)
+		}
+	})
+
+	t.Run("header written once", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewCSVEncoder(&sb, []string{"A"})
+		enc.Encode(rec{A: 1})
+		enc.Flush()
+		enc.Encode(rec{A: 2})
+		enc.Flush()
+		if want := "A\n1\n2\n"; sb.String() != want {
+			t.Errorf("\n---CSVEncoder---\n'%v'\n---want---\n'%v'", sb.String(), want)
+		}
+	})
+
+	t.Run("nil record", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewCSVEncoder(&sb, []string{"A", "B"}, NullAs("NULL"))
+		if err := enc.Encode(nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode((*rec)(nil)); err != nil {
+			t.Fatal(err)
+		}
+		enc.Flush()
+		if want := "A,B\nNULL,NULL\nNULL,NULL\n"; sb.String() != want {
+			t.Errorf("\n---CSVEncoder---\n'%v'\n---want---\n'%v'", sb.String(), want)
+		}
+	})
+
+	t.Run("skip nil records", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewCSVEncoder(&sb, []string{"A"}, SkipNilRecords())
+		enc.Encode((*rec)(nil))
+		enc.Encode(&rec{A: 1})
+		enc.Flush()
+		if want := "A\n1\n"; sb.String() != want {
+			t.Errorf("\n---CSVEncoder---\n'%v'\n---want---\n'%v'", sb.String(), want)
+		}
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		enc := NewCSVEncoder(&strings.Builder{}, []string{"A"})
+		if err := enc.Encode(map[string]int{"A": 1}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
+
+	t.Run("mismatched record types", func(t *testing.T) {
+		type other struct {
+			A int
+		}
+		enc := NewCSVEncoder(&strings.Builder{}, []string{"A"})
+		if err := enc.Encode(rec{A: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(other{A: 2}); !errors.Is(err, ErrMismatchedRecord) {
+			t.Error("expected an error when encoding a record of a different type")
+		}
+	})
+
+	// rows should reach the underlying writer as they are encoded rather than
+	// being held until Flush
+	t.Run("streams before flush", func(t *testing.T) {
+		const rows = 10000
+		cw := &countingWriter{}
+		enc := NewCSVEncoder(cw, []string{"A", "b"})
+		for i := 0; i < rows; i++ {
+			if err := enc.Encode(rec{A: i, b: fmt.Sprintf("row %d", i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if cw.n == 0 {
+			t.Fatal("no data reached the writer prior to Flush")
+		}
+		preFlush := cw.n
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// only the final, partial buffer should remain
+		if cw.n-preFlush > 4096 {
+			t.Errorf("%d bytes were buffered until Flush", cw.n-preFlush)
+		}
+	})
+}
+
+func TestJSONEncoder(t *testing.T) {
+	type nest struct {
+		F float32
+		U *uint16
+	}
+	type rec struct {
+		I    int
+		S    string
+		Arr  []int
+		Cmp  complex64
+		Nest nest
+	}
+	var u uint16 = 7
+	data := []rec{
+		{I: -1, S: "one", Arr: []int{1, 2}, Cmp: 1 + 2i, Nest: nest{F: 1.5, U: &u}},
+		{I: 2, S: "two\nlines", Arr: []int{}, Nest: nest{F: -0.25, U: &u}},
+		{I: 3, S: `"three"`, Nest: nest{U: &u}},
+	}
+	columns := []string{"I", "S", "Arr", "Cmp", "Nest.F", "Nest.U", "missing"}
+
+	encode := func(t *testing.T, mode JSONMode, records []rec) string {
+		var sb strings.Builder
+		enc := NewJSONEncoder(&sb, columns, mode)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return sb.String()
+	}
+
+	t.Run("array matches ToJSON", func(t *testing.T) {
+		want, err := ToJSON(data, columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := encode(t, JSONArray, data); actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("array, no records", func(t *testing.T) {
+		if actual := encode(t, JSONArray, nil); actual != "[]" {
+			t.Errorf("expected an empty array, got '%v'", actual)
+		}
+	})
+
+	t.Run("lines", func(t *testing.T) {
+		actual := encode(t, JSONLines, data)
+
+		scanner := bufio.NewScanner(strings.NewReader(actual))
+		var i int
+		for ; scanner.Scan(); i++ {
+			if i >= len(data) {
+				t.Fatalf("more lines than records:\n%v", actual)
+			}
+			// each line should match the equivalent single-record array
+			want, err := ToJSON(data[i:i+1], columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if "["+scanner.Text()+"]" != want {
+				t.Errorf("line %d mismatch:\nwant: '%v'\nactual: '[%v]'", i, want, scanner.Text())
+			}
+			if !json.Valid(scanner.Bytes()) {
+				t.Errorf("line %d is not valid JSON: %v", i, scanner.Text())
+			}
+		}
+		if i != len(data) {
+			t.Errorf("expected %d lines, got %d:\n%v", len(data), i, actual)
+		}
+		if !strings.HasSuffix(actual, "}\n") {
+			t.Errorf("expected output to be newline-terminated:\n%v", actual)
+		}
+	})
+
+	t.Run("lines, no records", func(t *testing.T) {
+		if actual := encode(t, JSONLines, nil); actual != "" {
+			t.Errorf("expected no output, got '%v'", actual)
+		}
+	})
+
+	t.Run("field typing", func(t *testing.T) {
+		actual := encode(t, JSONLines, data[:1])
+		var m map[string]any
+		if err := json.Unmarshal([]byte(actual), &m); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := m["I"].(float64); !ok {
+			t.Errorf("I should be a number, got %T", m["I"])
+		}
+		if _, ok := m["S"].(string); !ok {
+			t.Errorf("S should be a string, got %T", m["S"])
+		}
+		if _, ok := m["Arr"].([]any); !ok {
+			t.Errorf("Arr should be an array, got %T", m["Arr"])
+		}
+		if cmp, ok := m["Cmp"].(map[string]any); !ok || cmp["Real"] != 1.0 || cmp["Imaginary"] != 2.0 {
+			t.Errorf("Cmp should be a complex object, got %v", m["Cmp"])
+		}
+		if nest, ok := m["Nest"].(map[string]any); !ok || nest["U"] != 7.0 {
+			t.Errorf("Nest should be a nested object, got %v", m["Nest"])
+		}
+		if _, found := m["missing"]; found {
+			t.Error("unknown column should not be output")
+		}
+	})
+
+	t.Run("encode after close", func(t *testing.T) {
+		enc := NewJSONEncoder(&strings.Builder{}, columns, JSONLines)
+		enc.Close()
+		if err := enc.Encode(data[0]); !errors.Is(err, ErrEncoderClosed) {
+			t.Error("expected an error encoding to a closed encoder")
+		}
+	})
+
+	t.Run("nil records", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewJSONEncoder(&sb, []string{"I"}, JSONArray)
+		enc.Encode(&data[0])
+		enc.Encode((*rec)(nil))
+		enc.Encode(nil)
+		enc.Close()
+		if want := `[{"I":-1},null,null]`; sb.String() != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, sb.String())
+		}
+
+		sb.Reset()
+		enc = NewJSONEncoder(&sb, []string{"I"}, JSONLines, SkipNilRecords())
+		enc.Encode((*rec)(nil))
+		enc.Encode(&data[0])
+		enc.Close()
+		if want := `{"I":-1}` + "\n"; sb.String() != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, sb.String())
+		}
+	})
+
+	t.Run("mismatched record types", func(t *testing.T) {
+		type other struct {
+			I int
+		}
+		enc := NewJSONEncoder(&strings.Builder{}, columns, JSONArray)
+		if err := enc.Encode(data[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Encode(other{I: 2}); !errors.Is(err, ErrMismatchedRecord) {
+			t.Error("expected an error when encoding a record of a different type")
+		}
+	})
+}
@@ -0,0 +1,562 @@
+package weave
+
+import (
+	"cmp"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter returns the output representation of a value.
+// It is given the value after any pointers to it are dereferenced.
+type Formatter func(v any) string
+
+// FormatType registers the given formatter for every value of type T, in place
+// of the default representation, in all output modules.
+// JSON outputs formatted values as strings.
+//
+// T should be a concrete type; values are matched by their dynamic type, so a
+// formatter for an interface type never applies. A formatter for a pointer
+// type (ex: *big.Int) takes precedence over one for the type it points to.
+// Registering a second formatter for the same type replaces the first.
+func FormatType[T any](f func(T) string) Option {
+	t := reflect.TypeFor[T]()
+	return func(o *options) {
+		if o.formatters == nil {
+			o.formatters = make(map[reflect.Type]Formatter)
+		}
+		o.formatters[t] = func(v any) string { return f(v.(T)) }
+	}
+}
+
+// FormatColumn registers the given formatter for the column with the given
+// qualified name (its Path, not its alias), taking precedence over any type
+// formatters.
+// The values of a wildcard column are formatted individually.
+func FormatColumn(path string, f Formatter) Option {
+	return func(o *options) {
+		if o.columnFormatters == nil {
+			o.columnFormatters = make(map[string]Formatter)
+		}
+		o.columnFormatters[path] = f
+	}
+}
+
+// DefaultFormatters registers the built-in formatters:
+// time.Time as RFC 3339 (with nanoseconds, if present), time.Duration as its
+// String(), and []byte as standard base64.
+// Formatters registered after it replace the built-ins for the same type.
+func DefaultFormatters() Option {
+	return func(o *options) {
+		FormatType(FormatTime(time.RFC3339Nano))(o)
+		FormatType(FormatDuration(0))(o)
+		FormatType(FormatBase64)(o)
+	}
+}
+
+//#region built-ins
+
+// FormatTime returns a time.Time formatter using the given layout
+// (ex: time.RFC3339).
+func FormatTime(layout string) func(time.Time) string {
+	return func(t time.Time) string {
+		return t.Format(layout)
+	}
+}
+
+// FormatDuration returns a time.Duration formatter outputting the duration as
+// a decimal count of the given unit (ex: time.Second outputs 1.5s as "1.5").
+// A unit of 0 outputs the duration's String() (ex: "1m30s").
+func FormatDuration(unit time.Duration) func(time.Duration) string {
+	return func(d time.Duration) string {
+		if unit == 0 {
+			return d.String()
+		}
+		return strconv.FormatFloat(float64(d)/float64(unit), 'f', -1, 64)
+	}
+}
+
+// FormatHex formats a []byte as lowercase hexadecimal.
+func FormatHex(b []byte) string {
+	return hex.EncodeToString(b)
+}
+
+// FormatBase64 formats a []byte as standard, padded base64.
+func FormatBase64(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+//#endregion built-ins
+
+// format returns the formatted representation of the given value of the given
+// column, if a formatter applies to it.
+// Column formatters take precedence over type formatters; nil values are never
+// formatted.
+func format(data reflect.Value, cf *columnField, o *options) (string, bool) {
+	if cf.format != nil {
+		if d, ok := indirect(data); ok && d.IsValid() && d.CanInterface() {
+			return cf.format(d.Interface()), true
+		}
+		return "", false
+	}
+	if len(o.formatters) == 0 {
+		return "", false
+	}
+	// check each level of indirection for a formatter
+	var guard cycleGuard
+	for data.IsValid() && data.CanInterface() {
+		if f, found := o.formatters[data.Type()]; found {
+			return f(data.Interface()), true
+		}
+		if (data.Kind() != reflect.Pointer && data.Kind() != reflect.Interface) || data.IsNil() || guard.cyclic(data) {
+			break
+		}
+		data = data.Elem()
+	}
+	return "", false
+}
+
+//#region marshalers
+
+// Interfaces through which values may define their own representation.
+//
+// Tabular modules consult, in order: Formatters, encoding.TextMarshaler,
+// fmt.Stringer, then driver.Valuer (representing the value it returns).
+// JSON modules consult, in order: Formatters, json.Marshaler,
+// encoding.TextMarshaler (as a string), then driver.Valuer; as with
+// encoding/json, fmt.Stringer is not consulted.
+var (
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	stringerType      = reflect.TypeFor[fmt.Stringer]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	valuerType        = reflect.TypeFor[driver.Valuer]()
+)
+
+// implementer returns the value, at any level of indirection from the given
+// value, that implements the given interface type.
+// Pointer receivers are also considered; a value that is not addressable is
+// copied so its pointer methods can be called.
+// Returns false if no value implements it (or can have its methods called,
+// such as unexported or nil values).
+func implementer(data reflect.Value, it reflect.Type) (reflect.Value, bool) {
+	var guard cycleGuard
+	for data.IsValid() && data.CanInterface() {
+		if (data.Kind() == reflect.Pointer || data.Kind() == reflect.Interface) && (data.IsNil() || guard.cyclic(data)) {
+			break
+		}
+		if data.Type().Implements(it) {
+			return data, true
+		}
+		if reflect.PointerTo(data.Type()).Implements(it) {
+			if data.CanAddr() {
+				return data.Addr(), true
+			}
+			cp := reflect.New(data.Type())
+			cp.Elem().Set(data)
+			return cp, true
+		}
+		if data.Kind() != reflect.Pointer && data.Kind() != reflect.Interface {
+			break
+		}
+		data = data.Elem()
+	}
+	return reflect.Value{}, false
+}
+
+// marshalText returns the text representation of the given value as defined
+// by its encoding.TextMarshaler or, failing that, fmt.Stringer implementation.
+// Returns false if it implements neither (or MarshalText fails and it does not
+// implement fmt.Stringer).
+func marshalText(data reflect.Value) (string, bool) {
+	if v, ok := implementer(data, textMarshalerType); ok {
+		if b, err := v.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(b), true
+		}
+	}
+	if v, ok := implementer(data, stringerType); ok {
+		return v.Interface().(fmt.Stringer).String(), true
+	}
+	return "", false
+}
+
+// marshalJSON returns the JSON representation of the given value as defined by
+// its json.Marshaler or, failing that, encoding.TextMarshaler implementation.
+// Returns false if it implements neither.
+func marshalJSON(data reflect.Value) (any, bool, error) {
+	if v, ok := implementer(data, jsonMarshalerType); ok {
+		b, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, true, err
+		}
+		if !json.Valid(b) { // would otherwise invalidate the entire object
+			return nil, true, fmt.Errorf("%v.MarshalJSON returned invalid JSON", v.Type())
+		}
+		return json.RawMessage(b), true, nil
+	}
+	if v, ok := implementer(data, textMarshalerType); ok {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, true, err
+		}
+		return string(b), true, nil
+	}
+	return nil, false, nil
+}
+
+// driverValue returns the value returned by the given value's driver.Valuer
+// implementation (ex: the String of a valid sql.NullString); invalid if the
+// Valuer returned nil (or failed).
+// Returns false if it does not implement driver.Valuer.
+func driverValue(data reflect.Value) (reflect.Value, bool) {
+	v, ok := implementer(data, valuerType)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	dv, err := v.Interface().(driver.Valuer).Value()
+	if err != nil {
+		return reflect.Value{}, true
+	}
+	return reflect.ValueOf(dv), true
+}
+
+// definesRepresentation returns whether the given type (or a pointer to it)
+// defines its own representation via any of the interfaces above.
+func definesRepresentation(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	for _, it := range []reflect.Type{jsonMarshalerType, textMarshalerType, stringerType, valuerType} {
+		if t.Implements(it) || pt.Implements(it) {
+			return true
+		}
+	}
+	return false
+}
+
+//#endregion marshalers
+
+//#region leaf types
+
+// DefaultLeafTypes returns the struct types StructFields reports as single
+// fields by default: time.Time, big.Int, big.Float, big.Rat, netip.Addr,
+// netip.AddrPort, netip.Prefix, url.URL, and the sql.Null* types.
+//
+// (Most of these define their own representation and are therefore leaves
+// regardless; see LeafTypes.)
+func DefaultLeafTypes() []reflect.Type {
+	return []reflect.Type{
+		reflect.TypeFor[time.Time](),
+		reflect.TypeFor[big.Int](),
+		reflect.TypeFor[big.Float](),
+		reflect.TypeFor[big.Rat](),
+		reflect.TypeFor[netip.Addr](),
+		reflect.TypeFor[netip.AddrPort](),
+		reflect.TypeFor[netip.Prefix](),
+		reflect.TypeFor[url.URL](),
+		reflect.TypeFor[sql.NullBool](),
+		reflect.TypeFor[sql.NullByte](),
+		reflect.TypeFor[sql.NullFloat64](),
+		reflect.TypeFor[sql.NullInt16](),
+		reflect.TypeFor[sql.NullInt32](),
+		reflect.TypeFor[sql.NullInt64](),
+		reflect.TypeFor[sql.NullString](),
+		reflect.TypeFor[sql.NullTime](),
+	}
+}
+
+// defaultLeafTypes is the set form of DefaultLeafTypes, shared by every
+// options that has not been given LeafTypes.
+// ! never modified
+var defaultLeafTypes = leafSet(DefaultLeafTypes())
+
+// leafSet returns the given types as a set.
+func leafSet(types []reflect.Type) map[reflect.Type]bool {
+	set := make(map[reflect.Type]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
+
+// isLeaf returns whether a field of the given type is a single value, rather
+// than a struct to descend into.
+func (o *options) isLeaf(t reflect.Type) bool {
+	return o.leafTypes[t] || definesRepresentation(t)
+}
+
+//#endregion leaf types
+
+//#region composite values
+
+// errorType is the reflect.Type of the error interface.
+var errorType = reflect.TypeFor[error]()
+
+// refKey identifies a pointer, map, or slice for cycle detection.
+type refKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// reference returns the refKey of the given value, if it is a non-nil pointer,
+// map, or (non-empty) slice.
+func reference(data reflect.Value) (refKey, bool) {
+	switch data.Kind() {
+	case reflect.Pointer, reflect.Map:
+		if !data.IsNil() {
+			return refKey{data.Pointer(), data.Type()}, true
+		}
+	case reflect.Slice:
+		if data.Len() > 0 {
+			return refKey{data.Pointer(), data.Type()}, true
+		}
+	}
+	return refKey{}, false
+}
+
+// printValue returns the representation of the given value as given by fmt's
+// %v, except that pointers are followed (rather than printed as addresses) so
+// the result is deterministic, and a reference to a value already being
+// printed (a cycle) is printed as "<cycle>".
+// Nested values implementing error or fmt.Stringer are printed by it, as they
+// are by fmt.
+func printValue(data reflect.Value) string {
+	var sb strings.Builder
+	(&valuePrinter{sb: &sb, path: make(map[refKey]bool)}).print(data, 0)
+	return sb.String()
+}
+
+type valuePrinter struct {
+	sb   *strings.Builder
+	path map[refKey]bool // references being printed
+}
+
+func (p *valuePrinter) print(data reflect.Value, depth int) {
+	if !data.IsValid() {
+		p.sb.WriteString("<nil>")
+		return
+	}
+	if depth > 0 && data.CanInterface() && (data.Kind() != reflect.Pointer || !data.IsNil()) {
+		if data.Type().Implements(errorType) {
+			p.sb.WriteString(data.Interface().(error).Error())
+			return
+		}
+		if v, ok := implementer(data, stringerType); ok {
+			p.sb.WriteString(v.Interface().(fmt.Stringer).String())
+			return
+		}
+	}
+	if ref, ok := reference(data); ok {
+		if p.path[ref] {
+			p.sb.WriteString("<cycle>")
+			return
+		}
+		p.path[ref] = true
+		defer delete(p.path, ref)
+	}
+	switch data.Kind() {
+	case reflect.Pointer:
+		if data.IsNil() {
+			p.sb.WriteString("<nil>")
+			return
+		}
+		switch data.Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+			p.sb.WriteByte('&')
+		}
+		p.print(data.Elem(), depth+1)
+	case reflect.Interface:
+		if data.IsNil() {
+			p.sb.WriteString("<nil>")
+			return
+		}
+		p.print(data.Elem(), depth+1)
+	case reflect.Struct:
+		p.sb.WriteByte('{')
+		for i := range data.NumField() {
+			if i > 0 {
+				p.sb.WriteByte(' ')
+			}
+			p.print(data.Field(i), depth+1)
+		}
+		p.sb.WriteByte('}')
+	case reflect.Slice, reflect.Array:
+		p.sb.WriteByte('[')
+		for i := range data.Len() {
+			if i > 0 {
+				p.sb.WriteByte(' ')
+			}
+			p.print(data.Index(i), depth+1)
+		}
+		p.sb.WriteByte(']')
+	case reflect.Map:
+		p.sb.WriteString("map[")
+		keys := data.MapKeys()
+		slices.SortFunc(keys, compareKeys)
+		for i, k := range keys {
+			if i > 0 {
+				p.sb.WriteByte(' ')
+			}
+			p.print(k, depth+1)
+			p.sb.WriteByte(':')
+			p.print(data.MapIndex(k), depth+1)
+		}
+		p.sb.WriteByte(']')
+	default:
+		fmt.Fprintf(p.sb, "%v", data)
+	}
+}
+
+// compareKeys orders map keys as fmt does for basic kinds: numerically,
+// lexically, or false before true. Other kinds are ordered by their %v.
+func compareKeys(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Bool:
+		if a.Bool() == b.Bool() {
+			return 0
+		} else if b.Bool() {
+			return -1
+		}
+		return 1
+	}
+	return cmp.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// isCyclic returns whether the given value references itself via its
+// exported fields (or elements), such that encoding/json could not marshal it.
+// Values implementing json.Marshaler or encoding.TextMarshaler are not
+// descended into.
+func isCyclic(data reflect.Value) bool {
+	return cycleSearch{path: make(map[refKey]bool), done: make(map[refKey]bool)}.search(data)
+}
+
+type cycleSearch struct {
+	path map[refKey]bool // references being searched
+	done map[refKey]bool // references fully searched
+}
+
+func (c cycleSearch) search(data reflect.Value) bool {
+	if !data.IsValid() {
+		return false
+	}
+	if data.CanInterface() && (data.Type().Implements(jsonMarshalerType) || data.Type().Implements(textMarshalerType)) {
+		return false
+	}
+	if ref, ok := reference(data); ok {
+		if c.path[ref] {
+			return true
+		}
+		if c.done[ref] {
+			return false
+		}
+		c.path[ref] = true
+		defer func() {
+			delete(c.path, ref)
+			c.done[ref] = true
+		}()
+	}
+	switch data.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return !data.IsNil() && c.search(data.Elem())
+	case reflect.Struct:
+		for i := range data.NumField() {
+			if data.Type().Field(i).IsExported() && c.search(data.Field(i)) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range data.Len() {
+			if c.search(data.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for iter := data.MapRange(); iter.Next(); {
+			if c.search(iter.Value()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// acyclicJSON returns the given cyclic value as nested maps and slices that
+// encoding/json can marshal, with a reference to a value already being
+// output (a cycle) replaced by nil (null).
+// Struct fields are named as encoding/json would (per their json tags);
+// embedded structs are nested under their type name rather than promoted.
+func acyclicJSON(data reflect.Value, path map[refKey]bool) any {
+	if !data.IsValid() {
+		return nil
+	}
+	if data.CanInterface() && (data.Type().Implements(jsonMarshalerType) || data.Type().Implements(textMarshalerType)) {
+		return data.Interface()
+	}
+	if ref, ok := reference(data); ok {
+		if path[ref] {
+			return nil
+		}
+		path[ref] = true
+		defer delete(path, ref)
+	}
+	switch data.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if data.IsNil() {
+			return nil
+		}
+		return acyclicJSON(data.Elem(), path)
+	case reflect.Struct:
+		tags := &options{tagKeys: []string{"json"}}
+		obj := make(map[string]any, data.NumField())
+		for i := range data.NumField() {
+			f := data.Type().Field(i)
+			name, omitEmpty, hidden := fieldName(f, tags)
+			if !f.IsExported() || hidden || (omitEmpty && isEmptyValue(data.Field(i))) {
+				continue
+			}
+			obj[name] = acyclicJSON(data.Field(i), path)
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		if data.Kind() == reflect.Slice && data.IsNil() {
+			return nil
+		}
+		arr := make([]any, data.Len())
+		for i := range arr {
+			arr[i] = acyclicJSON(data.Index(i), path)
+		}
+		return arr
+	case reflect.Map:
+		if data.IsNil() {
+			return nil
+		}
+		obj := make(map[string]any, data.Len())
+		for iter := data.MapRange(); iter.Next(); {
+			obj[fmt.Sprintf("%v", iter.Key())] = acyclicJSON(iter.Value(), path)
+		}
+		return obj
+	}
+	if !data.CanInterface() {
+		return fmt.Sprintf("%v", data)
+	}
+	return data.Interface()
+}
+
+//#endregion composite values
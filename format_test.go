@@ -0,0 +1,282 @@
+package weave
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatters(t *testing.T) {
+	type level int
+	type rec struct {
+		When    time.Time
+		WhenPtr *time.Time
+		Took    time.Duration
+		Raw     []byte
+		Level   level
+		Events  []time.Time
+	}
+	when := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	data := []rec{
+		{When: when, WhenPtr: &when, Took: 1500 * time.Millisecond, Raw: []byte{0xde, 0xad}, Level: 2, Events: []time.Time{when, when.Add(time.Hour)}},
+		{},
+	}
+	levelNames := FormatType(func(l level) string {
+		return [...]string{"debug", "info", "warn"}[l]
+	})
+
+	t.Run("ToCSV", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			columns []string
+			opts    []Option
+			want    string
+		}{
+			{"defaults", []string{"When", "WhenPtr", "Took", "Raw"}, []Option{DefaultFormatters(), NullAs("NULL")},
+				"When,WhenPtr,Took,Raw\n" +
+					"2024-05-06T07:08:09Z,2024-05-06T07:08:09Z,1.5s,3q0=\n" +
+					"0001-01-01T00:00:00Z,NULL,0s,"},
+			{"chosen layout and encoding", []string{"When", "Took", "Raw"},
+				[]Option{FormatType(FormatTime(time.DateOnly)), FormatType(FormatDuration(time.Second)), FormatType(FormatHex)},
+				"When,Took,Raw\n" +
+					"2024-05-06,1.5,dead\n" +
+					"0001-01-01,0,"},
+			{"later registration replaces", []string{"When"}, []Option{DefaultFormatters(), FormatType(FormatTime(time.Kitchen))},
+				"When\n" +
+					"7:08AM\n" +
+					"12:00AM"},
+			{"custom enum", []string{"Level"}, []Option{levelNames},
+				"Level\n" +
+					"warn\n" +
+					"debug"},
+			{"wildcard elements", []string{"Events.*"}, []Option{FormatType(FormatTime(time.TimeOnly))},
+				"Events.*\n" +
+					`"07:08:09,08:08:09"` + "\n"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				actual, err := ToCSV(data, tt.columns, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual != tt.want {
+					t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("column takes precedence", func(t *testing.T) {
+		actual, err := ToTable(data[:1], []string{"When", "WhenPtr"},
+			DefaultFormatters(),
+			FormatColumn("WhenPtr", func(v any) string { return strings.ToUpper(v.(time.Time).Weekday().String()) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().Headers("When", "WhenPtr").Rows(
+			[]string{"2024-05-06T07:08:09Z", "MONDAY"},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		actual, err := ToJSON(data, []string{"When", "WhenPtr", "Took", "Level"}, DefaultFormatters(), levelNames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"Level":"warn","Took":"1.5s","When":"2024-05-06T07:08:09Z","WhenPtr":"2024-05-06T07:08:09Z"},` +
+			`{"Level":"debug","Took":"0s","When":"0001-01-01T00:00:00Z","WhenPtr":null}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+}
+
+// implements all three interfaces, each distinctly
+type tri struct {
+	n int
+}
+
+func (t tri) String() string                { return "stringer" }
+func (t tri) MarshalText() ([]byte, error)  { return []byte("text"), nil }
+func (t *tri) MarshalJSON() ([]byte, error) { return []byte(`{"json":true}`), nil }
+
+// implements only fmt.Stringer, via a pointer receiver
+type ptrStringer struct {
+	inner int
+}
+
+func (p *ptrStringer) String() string { return "ptr stringer" }
+
+// fails to marshal
+type badText struct{}
+
+func (badText) MarshalText() ([]byte, error) { return nil, errors.New("bad text") }
+func (badText) String() string               { return "fallback" }
+
+type badJSON struct{}
+
+func (badJSON) MarshalJSON() ([]byte, error) { return []byte("{not json"), nil }
+
+func TestMarshalers(t *testing.T) {
+	type rec struct {
+		Tri     tri
+		TriPtr  *tri
+		PS      ptrStringer
+		Addr    netip.Addr
+		Bad     badText
+		NilTri  *tri
+		Created time.Time
+	}
+	data := []*rec{{
+		TriPtr:  &tri{},
+		Addr:    netip.MustParseAddr("10.0.0.1"),
+		Created: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+	}}
+
+	t.Run("ToCSV", func(t *testing.T) {
+		// TextMarshaler > Stringer; pointer receivers apply to addressable values
+		actual, err := ToCSV(data, []string{"Tri", "TriPtr", "PS", "Addr", "Bad", "NilTri", "Created"}, NullAs("NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "Tri,TriPtr,PS,Addr,Bad,NilTri,Created\n" +
+			"text,text,ptr stringer,10.0.0.1,fallback,NULL,2024-05-06T07:08:09Z"
+		if actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("formatters take precedence", func(t *testing.T) {
+		actual, err := ToCSV(data, []string{"Tri"}, FormatType(func(tri) string { return "formatted" }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Tri\nformatted"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		// json.Marshaler > TextMarshaler; Stringer is not consulted
+		actual, err := ToJSON(data, []string{"Tri", "TriPtr", "Addr", "NilTri", "Created"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"Addr":"10.0.0.1","Created":"2024-05-06T07:08:09Z","NilTri":null,"Tri":{"json":true},"TriPtr":{"json":true}}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("marshal errors", func(t *testing.T) {
+		if _, err := ToJSON(data, []string{"Bad"}); err == nil {
+			t.Error("expected the MarshalText error to be returned")
+		}
+		type withBadJSON struct {
+			B badJSON
+		}
+		if _, err := ToJSON([]withBadJSON{{}}, []string{"B"}); err == nil {
+			t.Error("expected an error for invalid MarshalJSON output")
+		}
+	})
+
+	t.Run("StructFields leaves", func(t *testing.T) {
+		columns, err := StructFields(rec{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Tri", "TriPtr", "PS", "Addr", "Bad", "NilTri", "Created"}
+		if !reflect.DeepEqual(columns, want) {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, columns)
+		}
+	})
+}
+
+func TestLeafTypes(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	type rec struct {
+		When  time.Time
+		Big   big.Int
+		Ratio *big.Float
+		Link  url.URL
+		Name  sql.NullString
+		Count sql.NullInt64
+		Gen   sql.Null[int]
+		Pt    point
+	}
+	data := []rec{{
+		When:  time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Ratio: big.NewFloat(1.5),
+		Link:  url.URL{Scheme: "https", Host: "example.com", Path: "/a"},
+		Name:  sql.NullString{String: "n", Valid: true},
+		Gen:   sql.Null[int]{V: 4, Valid: true},
+		Pt:    point{1, 2},
+	}}
+	data[0].Big.SetInt64(42)
+
+	t.Run("StructFields", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			exportedOnly bool
+			opts         []Option
+			want         []string
+		}{
+			{"defaults", false, nil,
+				[]string{"When", "Big", "Ratio", "Link", "Name", "Count", "Gen", "Pt.X", "Pt.Y"}},
+			{"exported only", true, nil,
+				[]string{"When", "Big", "Ratio", "Link", "Name", "Count", "Gen", "Pt.X", "Pt.Y"}},
+			{"extended", false, []Option{LeafTypes(append(DefaultLeafTypes(), reflect.TypeFor[point]())...)},
+				[]string{"When", "Big", "Ratio", "Link", "Name", "Count", "Gen", "Pt"}},
+			{"emptied", false, []Option{LeafTypes()},
+				// each default defines its own representation
+				[]string{"When", "Big", "Ratio", "Link", "Name", "Count", "Gen", "Pt.X", "Pt.Y"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				columns, err := StructFields(rec{}, tt.exportedOnly, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(columns, tt.want) {
+					t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", tt.want, columns)
+				}
+			})
+		}
+	})
+
+	t.Run("ToCSVExclude", func(t *testing.T) {
+		// records are given by value, so pointer methods (ex: *big.Int's
+		// String) must be reached via a copy
+		actual, err := ToCSVExclude(data, []string{"Pt"}, NullAs("NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "When,Big,Ratio,Link,Name,Count,Gen\n" +
+			"2024-05-06T07:08:09Z,42,1.5,https://example.com/a,n,NULL,4"
+		if actual != want {
+			t.Errorf("\n---ToCSVExclude()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("ToJSONExclude", func(t *testing.T) {
+		actual, err := ToJSONExclude(data, []string{"Pt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"Big":42,"Count":null,"Gen":4,"Link":"https://example.com/a","Name":"n","Ratio":"1.5","When":"2024-05-06T07:08:09Z"}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+}
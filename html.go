@@ -0,0 +1,75 @@
+package weave
+
+import (
+	"html/template"
+	"strings"
+)
+
+// Given an array of an arbitrary struct and the list of *fully-qualified* fields,
+// outputs an HTML <table> (with a <thead> of the column names and a <tbody> of
+// a row per record) containing the data in the array of the struct.
+// Cells are stringified identically to ToTable, then HTML escaped, so no value
+// can inject markup.
+//
+// Columns and rows may be given CSS classes via ColumnClass and RowClass.
+//
+// Columns may be given as qualified names or as Column specs.
+//
+// ! Returns the empty string (and no error) if columns or st are empty
+func ToHTML[Any any, C Columns](st []Any, columns C, opts ...Option) (string, error) {
+	if st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
+		return "", nil
+	}
+
+	o := newOptions(opts)
+	r := resolver{columns: toColumns(columns), opts: o}
+
+	rows, records, err := tableRows(st, &r)
+	if err != nil {
+		return "", err
+	}
+
+	// class attribute of each column's cells
+	classes := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		classes[i] = htmlClass(o.columnClasses[col.Path])
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<table>\n<thead>\n<tr>")
+	for i, name := range columnNames(r.columns) {
+		writeHTMLCell(&sb, "th", classes[i], name)
+	}
+	sb.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for i, row := range rows {
+		sb.WriteString("<tr")
+		if o.rowClass != nil {
+			sb.WriteString(htmlClass(o.rowClass(i, st[records[i]])))
+		}
+		sb.WriteString(">")
+		for k, cell := range row {
+			writeHTMLCell(&sb, "td", classes[k], cell)
+		}
+		sb.WriteString("</tr>\n")
+	}
+	sb.WriteString("</tbody>\n</table>")
+
+	return sb.String(), nil
+}
+
+// helper function for ToHTML
+// writes the given cell, escaped, as an element of the given tag.
+func writeHTMLCell(sb *strings.Builder, tag, class, cell string) {
+	sb.WriteString("<" + tag + class + ">")
+	sb.WriteString(template.HTMLEscapeString(cell))
+	sb.WriteString("</" + tag + ">")
+}
+
+// htmlClass returns the escaped class attribute (with a leading space) for the
+// given class(es); the empty string if there are none.
+func htmlClass(class string) string {
+	if class == "" {
+		return ""
+	}
+	return ` class="` + template.HTMLEscapeString(class) + `"`
+}
@@ -0,0 +1,102 @@
+package weave
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	type rec struct {
+		Name  string
+		Count int
+		Tags  []string
+	}
+
+	t.Run("superfluous", func(t *testing.T) {
+		actual, err := ToHTML[any](nil, []string{"A"})
+		if err != nil {
+			t.Error("Expected no error, got: ", err)
+		}
+		if actual != "" {
+			t.Errorf("string mismatch.\nactual%s\nexpected the empty string", actual)
+		}
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		if _, err := ToHTML([]int{1}, []string{"A"}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		data    []*rec
+		columns []Column
+		opts    []Option
+		want    string
+	}{
+		{
+			name:    "basic",
+			data:    []*rec{{Name: "a", Count: 1}, {Name: "b", Count: 2}},
+			columns: toColumns([]string{"Name", "Count"}),
+			want: "<table>\n<thead>\n<tr><th>Name</th><th>Count</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td>a</td><td>1</td></tr>\n" +
+				"<tr><td>b</td><td>2</td></tr>\n" +
+				"</tbody>\n</table>",
+		},
+		{
+			name:    "escaping",
+			data:    []*rec{{Name: `<script>alert("x")</script> & 'y'`}},
+			columns: []Column{{Path: "Name", Alias: "<b>name</b>"}},
+			want: "<table>\n<thead>\n<tr><th>&lt;b&gt;name&lt;/b&gt;</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; &#39;y&#39;</td></tr>\n" +
+				"</tbody>\n</table>",
+		},
+		{
+			name:    "classes",
+			data:    []*rec{{Name: "a", Count: 1}, nil, {Name: "b", Count: 2}},
+			columns: toColumns([]string{"Name", "Count"}),
+			opts: []Option{
+				ColumnClass("Count", `num" onclick="x`),
+				RowClass(func(row int, record any) string {
+					if r := record.(*rec); r == nil {
+						return "null"
+					} else if r.Count%2 == 0 {
+						return "even"
+					}
+					return ""
+				}),
+			},
+			want: "<table>\n<thead>\n<tr><th>Name</th><th class=\"num&#34; onclick=&#34;x\">Count</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td>a</td><td class=\"num&#34; onclick=&#34;x\">1</td></tr>\n" +
+				"<tr class=\"null\"><td></td><td class=\"num&#34; onclick=&#34;x\"></td></tr>\n" +
+				"<tr class=\"even\"><td>b</td><td class=\"num&#34; onclick=&#34;x\">2</td></tr>\n" +
+				"</tbody>\n</table>",
+		},
+		{
+			name:    "exploded rows share a record",
+			data:    []*rec{{Name: "a", Tags: []string{"x", "y"}}, {Name: "b", Tags: []string{"z"}}},
+			columns: toColumns([]string{"Name", "Tags"}),
+			opts: []Option{
+				Explode("Tags"),
+				RowClass(func(row int, record any) string { return record.(*rec).Name }),
+			},
+			want: "<table>\n<thead>\n<tr><th>Name</th><th>Tags</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr class=\"a\"><td>a</td><td>x</td></tr>\n" +
+				"<tr class=\"a\"><td>a</td><td>y</td></tr>\n" +
+				"<tr class=\"b\"><td>b</td><td>z</td></tr>\n" +
+				"</tbody>\n</table>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ToHTML(tt.data, tt.columns, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.want {
+				t.Errorf("\n---ToHTML()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+			}
+		})
+	}
+}
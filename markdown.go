@@ -0,0 +1,102 @@
+package weave
+
+import (
+	"strings"
+)
+
+// Alignment is the horizontal alignment of a column's cells in Markdown output
+// (see Align).
+type Alignment uint8
+
+const (
+	// Left to the renderer; typically left aligned.
+	AlignDefault Alignment = iota
+	AlignLeft
+	AlignCenter
+	AlignRight
+)
+
+// delimiter returns the delimiter row cell specifying the alignment.
+func (a Alignment) delimiter() string {
+	switch a {
+	case AlignLeft:
+		return ":---"
+	case AlignCenter:
+		return ":---:"
+	case AlignRight:
+		return "---:"
+	default:
+		return "---"
+	}
+}
+
+// Given an array of an arbitrary struct and the list of *fully-qualified* fields,
+// outputs a GitHub-flavored Markdown table containing the data in the array of
+// the struct.
+// Cells are stringified identically to ToTable. Pipes and backslashes are
+// escaped and line breaks are replaced with <br>, so every record remains a
+// single row.
+//
+// Columns may be aligned via Align.
+//
+// Columns may be given as qualified names or as Column specs.
+//
+// ! Returns the empty string (and no error) if columns or st are empty
+func ToMarkdown[Any any, C Columns](st []Any, columns C, opts ...Option) (string, error) {
+	if st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
+		return "", nil
+	}
+
+	o := newOptions(opts)
+	r := resolver{columns: toColumns(columns), opts: o}
+
+	rows, _, err := tableRows(st, &r)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	// header
+	writeMarkdownRow(&sb, columnNames(r.columns))
+	// delimiter row
+	sb.WriteString("\n|")
+	for _, col := range r.columns {
+		sb.WriteString(" ")
+		sb.WriteString(o.alignments[col.Path].delimiter())
+		sb.WriteString(" |")
+	}
+	for _, row := range rows {
+		sb.WriteString("\n")
+		writeMarkdownRow(&sb, row)
+	}
+
+	return sb.String(), nil
+}
+
+// helper function for ToMarkdown
+// writes the given cells as a table row, sans line terminator.
+func writeMarkdownRow(sb *strings.Builder, cells []string) {
+	sb.WriteString("|")
+	for _, cell := range cells {
+		sb.WriteString(" ")
+		sb.WriteString(escapeMarkdownCell(cell))
+		sb.WriteString(" |")
+	}
+}
+
+// markdownEscaper escapes the characters that would otherwise split a cell
+// (pipes) or a row (line breaks).
+// Backslashes are escaped so data cannot escape a pipe itself.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`|`, `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+)
+
+// escapeMarkdownCell returns the given field escaped for use as a GFM table
+// cell.
+func escapeMarkdownCell(field string) string {
+	return markdownEscaper.Replace(field)
+}
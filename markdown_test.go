@@ -0,0 +1,90 @@
+package weave
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToMarkdown(t *testing.T) {
+	type inner struct {
+		Note string
+	}
+	type rec struct {
+		Name  string
+		Count int
+		In    *inner
+	}
+
+	t.Run("superfluous", func(t *testing.T) {
+		actual, err := ToMarkdown[any](nil, []string{"A"})
+		if err != nil {
+			t.Error("Expected no error, got: ", err)
+		}
+		if actual != "" {
+			t.Errorf("string mismatch.\nactual%s\nexpected the empty string", actual)
+		}
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		if _, err := ToMarkdown([]int{1}, []string{"A"}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		data    []*rec
+		columns []Column
+		opts    []Option
+		want    string
+	}{
+		{
+			name:    "basic",
+			data:    []*rec{{Name: "a", Count: 1, In: &inner{Note: "x"}}, {Name: "b", Count: 2}},
+			columns: toColumns([]string{"Name", "Count", "In.Note"}),
+			want: "| Name | Count | In.Note |\n" +
+				"| --- | --- | --- |\n" +
+				"| a | 1 | x |\n" +
+				"| b | 2 |  |",
+		},
+		{
+			name:    "escaping",
+			data:    []*rec{{Name: "a|b", In: &inner{Note: "line1\nline2\r\nline3"}}, {Name: `back\slash \|`}},
+			columns: toColumns([]string{"Name", "In.Note"}),
+			want: "| Name | In.Note |\n" +
+				"| --- | --- |\n" +
+				`| a\|b | line1<br>line2<br>line3 |` + "\n" +
+				`| back\\slash \\\| |  |`,
+		},
+		{
+			name:    "aligned",
+			data:    []*rec{{Name: "a", Count: 1}},
+			columns: []Column{{Path: "Name", Alias: "N|ame"}, {Path: "Count"}, {Path: "In.Note"}},
+			opts:    []Option{Align("Name", AlignLeft), Align("Count", AlignRight), Align("In.Note", AlignCenter)},
+			want: `| N\|ame | Count | In.Note |` + "\n" +
+				"| :--- | ---: | :---: |\n" +
+				"| a | 1 |  |",
+		},
+		{
+			name:    "nil records",
+			data:    []*rec{nil, {Name: "a"}},
+			columns: toColumns([]string{"Name", "Count"}),
+			opts:    []Option{NullAs("NULL")},
+			want: "| Name | Count |\n" +
+				"| --- | --- |\n" +
+				"| NULL | NULL |\n" +
+				"| a | 0 |",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ToMarkdown(tt.data, tt.columns, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.want {
+				t.Errorf("\n---ToMarkdown()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+			}
+		})
+	}
+}
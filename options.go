@@ -0,0 +1,289 @@
+package weave
+
+import (
+	"reflect"
+
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// Option alters the behavior of the output modules, encoders, and helpers that
+// accept it.
+// Options that do not apply to a given function are ignored.
+type Option func(*options)
+
+// options is the configuration built from a set of Options
+type options struct {
+	strict          bool                // error on unresolved columns
+	caseInsensitive bool                // case-insensitive qualified names
+	tagKeys         []string            // struct tag keys naming fields, by precedence
+	nullRepr        string              // tabular representation of nil values
+	joiner          string              // tabular separator of wildcard values
+	skipNil         bool                // omit nil records from output
+	explode         string              // qualified slice to output a row per element of
+	maxDepth        int                 // StructFields depth limit; 0 if unlimited
+	explodeLeft     bool                // output a row for records with an empty explode slice
+	heterogeneous   bool                // records may be of differing struct types
+	tableStyle      func() *table.Table // ToTable style func
+	quote           rune                // delimited quote character
+	crlf            bool                // delimited lines are terminated by CRLF
+	bom             bool                // prefix delimited output with a byte order mark
+	// value formatters by type (see FormatType)
+	formatters map[reflect.Type]Formatter
+	// value formatters by qualified column name (see FormatColumn)
+	columnFormatters map[string]Formatter
+	// struct types output as a single value (see LeafTypes)
+	leafTypes map[reflect.Type]bool
+	// Markdown column alignments by qualified column name (see Align)
+	alignments map[string]Alignment
+	// HTML cell classes by qualified column name (see ColumnClass)
+	columnClasses map[string]string
+	// HTML row class func (see RowClass)
+	rowClass  func(row int, record any) string
+	xmlRoot   string   // XML root element name
+	xmlRecord string   // XML record element name
+	xmlAttrs  []string // qualified columns output as XML attributes
+	// qualified struct pointers output as null when nil (see ToJSONExclude)
+	nullStructs []string
+}
+
+// newOptions returns the configuration built from the given Options, applied
+// in order.
+func newOptions(opts []Option) *options {
+	o := &options{joiner: ",", quote: '"', xmlRoot: "records", xmlRecord: "record", leafTypes: defaultLeafTypes}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// Strict causes column resolution to fail with an *UnknownColumnError if any
+// requested column does not resolve to a field, rather than outputting an
+// empty value for it.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// CaseInsensitive causes qualified column names to be matched against fields
+// case-insensitively at every depth (ex: "host.ip" resolves to "Host.IP").
+// Exact matches are preferred; a qualification that differs only by case from
+// multiple fields fails with ErrAmbiguousColumn.
+func CaseInsensitive() Option {
+	return func(o *options) {
+		o.caseInsensitive = true
+	}
+}
+
+// Tags causes fields to be named by the first of the given struct tag keys
+// present on them, rather than by their Go names, for both resolving and
+// outputting qualified names.
+// If no keys are given, the "weave", "json", and "csv" keys are consulted, in
+// that order.
+//
+// Tags take the form `key:"name,omitempty"`. An empty name keeps the Go name.
+// A tag of "-" hides the field (and its descendants) entirely. omitempty omits
+// empty values (as defined by encoding/json) from JSON and XML output; it does
+// not affect tabular output.
+func Tags(keys ...string) Option {
+	if len(keys) == 0 {
+		keys = []string{"weave", "json", "csv"}
+	}
+	return func(o *options) {
+		o.tagKeys = keys
+	}
+}
+
+// NullAs sets the text output in place of nil values (nil pointers and
+// interfaces, including fields reached through a nil pointer) by tabular
+// modules. Defaults to the empty string (an empty cell), but a marker such as
+// "NULL" may be preferable to differentiate nil from empty values.
+//
+// JSON output always represents nil values as null.
+func NullAs(repr string) Option {
+	return func(o *options) {
+		o.nullRepr = repr
+	}
+}
+
+// Explode causes each record to be output as one row (or JSON object) per
+// element of the slice (or array) at the given qualified path, repeating the
+// record's other columns in each, similar to SQL's UNNEST.
+// Columns beneath the path address the fields of the row's element rather than
+// the slice; ex: exploding "Hops" makes "Hops.Addr" the Addr field of each
+// element and "Hops" the element itself.
+//
+// Records with an empty (or nil) slice are not output; see ExplodeLeft.
+func Explode(path string) Option {
+	return func(o *options) {
+		o.explode = path
+		o.explodeLeft = false
+	}
+}
+
+// ExplodeLeft is Explode, except records with an empty (or nil) slice are
+// output as a single row with null values for the columns beneath the path,
+// similar to a SQL left join.
+func ExplodeLeft(path string) Option {
+	return func(o *options) {
+		o.explode = path
+		o.explodeLeft = true
+	}
+}
+
+// Joiner sets the separator placed between the values of a wildcard column
+// (ex: "Hops.*.Addr") by tabular modules. Defaults to ",".
+//
+// JSON output always represents wildcard values as an array.
+func Joiner(sep string) Option {
+	return func(o *options) {
+		o.joiner = sep
+	}
+}
+
+// SkipNilRecords causes nil records (nil pointers to structs) to be omitted
+// from output entirely. By default, a nil record is output as a row of null
+// values (see NullAs) by tabular modules and as null by JSON modules.
+func SkipNilRecords() Option {
+	return func(o *options) {
+		o.skipNil = true
+	}
+}
+
+// Heterogeneous allows the records given to an output module or encoder to be
+// of differing struct types (ex: a []any event stream), rather than requiring
+// every record be of the same type as the first.
+// Columns are resolved separately for each type (and cached); columns a type
+// lacks are output as empty cells (or null values, in JSON).
+//
+// As any given type is expected to lack some columns, Strict does not apply.
+func Heterogeneous() Option {
+	return func(o *options) {
+		o.heterogeneous = true
+	}
+}
+
+// LeafTypes replaces the set of struct types StructFields reports as single
+// fields (rather than descending into), which is DefaultLeafTypes() if not
+// given. To extend the defaults, include them:
+//
+//	LeafTypes(append(DefaultLeafTypes(), reflect.TypeFor[Point]())...)
+//
+// Regardless of the set, struct types that define their own representation
+// (by implementing json.Marshaler, encoding.TextMarshaler, fmt.Stringer, or
+// driver.Valuer) are always leaves.
+// Leaf values are output as a single value by every output module.
+func LeafTypes(types ...reflect.Type) Option {
+	set := leafSet(types)
+	return func(o *options) {
+		o.leafTypes = set
+	}
+}
+
+// MaxDepth limits the depth StructFields descends into nested structs to the
+// given number of qualifications; a struct at the maximum depth is reported
+// as a single field (ex: with MaxDepth(2), "A.B" rather than "A.B.C").
+// Recursive types are descended into until the maximum depth, rather than only
+// once.
+// A depth < 1 removes the limit.
+func MaxDepth(depth int) Option {
+	return func(o *options) {
+		o.maxDepth = max(depth, 0)
+	}
+}
+
+// Quote sets the character delimited output (ToCSV, ToDelimited, and their
+// encoders) encloses fields in, when they require quoting. Quotes within a
+// field are escaped by doubling them. Defaults to '"'.
+func Quote(q rune) Option {
+	return func(o *options) {
+		o.quote = q
+	}
+}
+
+// CRLF causes delimited output to terminate lines with CRLF ("\r\n"), as
+// specified by RFC 4180 and expected by some spreadsheet software, rather than
+// LF.
+func CRLF() Option {
+	return func(o *options) {
+		o.crlf = true
+	}
+}
+
+// BOM causes delimited output to be prefixed with a UTF-8 byte order mark,
+// which some spreadsheet software (ex: Excel) requires to detect the encoding.
+func BOM() Option {
+	return func(o *options) {
+		o.bom = true
+	}
+}
+
+// Align sets the alignment of the cells of the given qualified column in
+// Markdown output. Columns are AlignDefault if not given.
+func Align(path string, a Alignment) Option {
+	return func(o *options) {
+		if o.alignments == nil {
+			o.alignments = make(map[string]Alignment)
+		}
+		o.alignments[path] = a
+	}
+}
+
+// ColumnClass sets the CSS class(es) of the header and data cells of the given
+// qualified column in HTML output.
+func ColumnClass(path, class string) Option {
+	return func(o *options) {
+		if o.columnClasses == nil {
+			o.columnClasses = make(map[string]string)
+		}
+		o.columnClasses[path] = class
+	}
+}
+
+// RowClass sets a func returning the CSS class(es) of each data row in HTML
+// output, given the index of the row and the record it was output from (nil or
+// a nil pointer, for nil records). An empty class omits the attribute.
+//
+// Exploded records output multiple rows, each given the same record.
+func RowClass(f func(row int, record any) string) Option {
+	return func(o *options) {
+		o.rowClass = f
+	}
+}
+
+// XMLRoot sets the name of the root element of XML output. Defaults to
+// "records".
+func XMLRoot(name string) Option {
+	return func(o *options) {
+		o.xmlRoot = name
+	}
+}
+
+// XMLRecord sets the name of the element each record is output as in XML
+// output. Defaults to "record".
+func XMLRecord(name string) Option {
+	return func(o *options) {
+		o.xmlRecord = name
+	}
+}
+
+// XMLAttrs causes the given qualified columns to be output as attributes of
+// their parent element, rather than as elements, in XML output; ex: "ID" as an
+// attribute of the record element and "Src.IP" as an attribute of the Src
+// element.
+// The values of a wildcard column are joined (see Joiner).
+func XMLAttrs(paths ...string) Option {
+	return func(o *options) {
+		o.xmlAttrs = append(o.xmlAttrs, paths...)
+	}
+}
+
+// TableStyle sets the style func used by ToTable. Uses DefaultTblStyle() if not
+// given.
+func TableStyle(styleFunc func() *table.Table) Option {
+	return func(o *options) {
+		o.tableStyle = styleFunc
+	}
+}
@@ -0,0 +1,178 @@
+package weave
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// schema is a set of columns compiled against a single struct type: each
+// column's resolved field (and how to render it), by position.
+// Schemas are immutable once compiled and are shared, via schemaCache, by
+// every output module and encoder given the same type, columns, and options.
+type schema struct {
+	fields  []*columnField // resolved field of each column; nil if unknown
+	explode *columnField   // slice to explode (see Explode); nil if none
+	// struct pointers output as null when nil (see ToJSONExclude)
+	nullStructs []nullStruct
+}
+
+// nullStruct is a struct pointer field output as null, rather than as its
+// fields, when nil.
+type nullStruct struct {
+	name string // qualified name
+	cf   *columnField
+}
+
+// schemaKey identifies a compiled schema.
+type schemaKey struct {
+	t       reflect.Type
+	columns string // qualified column names, NUL-separated
+	opts    string // options affecting resolution (see resolutionKey)
+}
+
+// schemaCache caches compiled schemas by schemaKey, so the columns of a given
+// type are generally only resolved once.
+//
+// As column sets may be built from data (ex: by MapKeys) or user input, the
+// cache is bounded: once it holds maxSchemas schemas, it is cleared, to be
+// repopulated by the schemas still in use.
+var (
+	schemaCache sync.Map     // schemaKey -> *schema
+	schemaCount atomic.Int64 // (approximate) number of schemas cached
+)
+
+// maxSchemas is the number of schemas schemaCache holds before being cleared.
+var maxSchemas int64 = 1024
+
+// compileSchema returns the schema of the given columns against the struct type
+// t, compiling and caching it if it is not already cached.
+// Column formatters are not part of the schema; see withFormatters.
+//
+// Errors (ex: unknown columns, if strict) are not cached.
+//
+// ! t must be a struct type
+func compileSchema(t reflect.Type, columns []Column, o *options) (*schema, error) {
+	var paths strings.Builder
+	for i := range columns {
+		if i > 0 {
+			paths.WriteByte(0)
+		}
+		paths.WriteString(columns[i].Path)
+	}
+	key := schemaKey{t: t, columns: paths.String(), opts: o.resolutionKey()}
+	if s, found := schemaCache.Load(key); found {
+		return s.(*schema), nil
+	}
+
+	explode, err := resolveExplode(t, o)
+	if err != nil {
+		return nil, err
+	}
+	columnMap, err := buildColumnMap(t, columnPaths(columns), o)
+	if err != nil {
+		return nil, err
+	}
+	s := &schema{fields: make([]*columnField, len(columns)), explode: explode}
+	for i := range columns {
+		s.fields[i] = columnMap[columns[i].Path]
+	}
+	for _, name := range o.nullStructs {
+		field, steps, found, err := resolvePath(name, t, o)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			s.nullStructs = append(s.nullStructs, nullStruct{name: name, cf: &columnField{steps: steps, typ: field.Type}})
+		}
+	}
+
+	actual, loaded := schemaCache.LoadOrStore(key, s)
+	if !loaded && schemaCount.Add(1) > maxSchemas {
+		schemaCache.Clear()
+		schemaCount.Store(0)
+	}
+	return actual.(*schema), nil
+}
+
+// withFormatters returns the schema with the given column formatters (see
+// FormatColumn) attached to its fields.
+// As schemas are shared, a copy is returned if any formatters apply.
+func (s *schema) withFormatters(columns []Column, formatters map[string]Formatter) *schema {
+	if len(formatters) == 0 {
+		return s
+	}
+	cp := &schema{fields: make([]*columnField, len(s.fields)), explode: s.explode, nullStructs: s.nullStructs}
+	for i, cf := range s.fields {
+		cp.fields[i] = cf
+		if f := formatters[columns[i].Path]; f != nil && cf != nil {
+			fcf := *cf
+			fcf.format = f
+			cp.fields[i] = &fcf
+		}
+	}
+	return cp
+}
+
+// resolutionKey returns a key identifying the options that affect how columns
+// are resolved.
+func (o *options) resolutionKey() string {
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatBool(o.strict))
+	sb.WriteString(strconv.FormatBool(o.caseInsensitive))
+	sb.WriteString(strconv.FormatBool(o.heterogeneous))
+	sb.WriteString(strconv.Quote(o.explode))
+	for _, k := range o.tagKeys {
+		sb.WriteString(strconv.Quote(k))
+	}
+	sb.WriteByte(0)
+	for _, name := range o.nullStructs {
+		sb.WriteString(strconv.Quote(name))
+	}
+	return sb.String()
+}
+
+//#region plain values
+
+// plainKind returns the kind of values of the given type if they can be
+// rendered directly from their kind (bools, numbers, and strings that do not
+// define their own representation), or reflect.Invalid if they cannot.
+// Pointers are dereferenced; interfaces are never plain, as their dynamic type
+// is unknown.
+func plainKind(t reflect.Type) reflect.Kind {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if !definesRepresentation(t) {
+			return t.Kind()
+		}
+	}
+	return reflect.Invalid
+}
+
+// plainString returns the string representation of a plain value of the given
+// kind, identical to that of fmt's %v.
+func plainString(data reflect.Value, kind reflect.Kind) string {
+	switch kind {
+	case reflect.Bool:
+		return strconv.FormatBool(data.Bool())
+	case reflect.String:
+		return data.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(data.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(data.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(data.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(data.Float(), 'g', -1, 64)
+	}
+	return ""
+}
+
+//#endregion plain values
@@ -0,0 +1,310 @@
+package weave
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type benchGeo struct {
+	Country string
+	City    string
+	Lat     float64
+	Lon     float64
+}
+
+type benchRecord struct {
+	ID   int
+	Host string
+	Src  struct {
+		IP   string
+		Port uint16
+		Geo  *benchGeo
+	}
+	Bytes   int64
+	Ratio   float32
+	OK      bool
+	Tags    []string
+	Created time.Time
+}
+
+var benchColumns = []string{"ID", "Host", "Src.IP", "Src.Port", "Src.Geo.Country", "Src.Geo.Lat", "Bytes", "Ratio", "OK", "Tags"}
+
+func benchRecords(n int) []*benchRecord {
+	recs := make([]*benchRecord, n)
+	for i := range recs {
+		recs[i] = &benchRecord{ID: i, Host: "host-" + strconv.Itoa(i), Bytes: int64(i) * 1024, Ratio: 0.5, OK: i%2 == 0, Tags: []string{"a", "b"}}
+		recs[i].Src.IP = "10.0.0." + strconv.Itoa(i%255)
+		recs[i].Src.Port = 443
+		recs[i].Src.Geo = &benchGeo{Country: "NZ", City: "Wellington", Lat: -41.28, Lon: 174.77}
+	}
+	return recs
+}
+
+func TestSchemaCache(t *testing.T) {
+	type rec struct {
+		A int
+		B struct {
+			C string `json:"c"`
+		}
+	}
+	rt := reflect.TypeFor[rec]()
+	columns := toColumns([]string{"A", "B.C"})
+
+	t.Run("compiled once", func(t *testing.T) {
+		first, err := compileSchema(rt, columns, newOptions(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := compileSchema(rt, columns, newOptions(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first != second {
+			t.Errorf("expected the cached schema, got a new one")
+		}
+		if len(first.fields) != 2 || first.fields[0] == nil || first.fields[1] == nil {
+			t.Errorf("expected both columns to resolve, got %v", first.fields)
+		}
+	})
+
+	t.Run("keyed by columns and options", func(t *testing.T) {
+		base, err := compileSchema(rt, columns, newOptions(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reordered, err := compileSchema(rt, toColumns([]string{"B.C", "A"}), newOptions(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tagged, err := compileSchema(rt, toColumns([]string{"A", "B.c"}), newOptions([]Option{Tags()}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		untagged, err := compileSchema(rt, toColumns([]string{"A", "B.c"}), newOptions(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if base == reordered || base == tagged || tagged == untagged {
+			t.Errorf("expected distinct schemas")
+		}
+		if reordered.fields[0].typ != base.fields[1].typ || reordered.fields[1].typ != base.fields[0].typ {
+			t.Errorf("expected reordered columns to resolve to the same fields")
+		}
+		if tagged.fields[1] == nil || untagged.fields[1] != nil {
+			t.Errorf("expected only the tagged schema to resolve the tag name")
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		for range 2 {
+			if _, err := compileSchema(rt, toColumns([]string{"A", "Z"}), newOptions([]Option{Strict()})); !errors.Is(err, ErrUnknownColumn) {
+				t.Errorf("expected '%v', got '%v'", ErrUnknownColumn, err)
+			}
+		}
+	})
+
+	t.Run("column formatters are not shared", func(t *testing.T) {
+		data := []rec{{A: 1}}
+		formatted, err := ToCSV(data, []string{"A"}, FormatColumn("A", func(v any) string { return "one" }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := ToCSV(data, []string{"A"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "A\none"; formatted != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, formatted)
+		}
+		if want := "A\n1"; plain != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, plain)
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		defer func(prior int64) { maxSchemas = prior }(maxSchemas)
+		maxSchemas = 8
+		// ex: dynamic columns built from data
+		for i := range 100 {
+			if _, err := compileSchema(rt, toColumns([]string{"A", "Labels." + strconv.Itoa(i)}), newOptions(nil)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var n int64
+		schemaCache.Range(func(_, _ any) bool {
+			n++
+			return true
+		})
+		if n > maxSchemas {
+			t.Errorf("expected at most %d cached schemas, got %d", maxSchemas, n)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		want, err := ToCSV([]rec{{A: 1}}, []string{"A", "B.C"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 50 {
+					if actual, err := ToCSV([]rec{{A: 1}}, []string{"A", "B.C"}); err != nil {
+						t.Error(err)
+					} else if actual != want {
+						t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestPlainValues(t *testing.T) {
+	type named int
+	tests := []any{
+		true, false, "", "str", named(-3),
+		int8(math.MinInt8), int16(-1), int32(7), int64(math.MaxInt64), int(0),
+		uint8(255), uint16(1), uint32(math.MaxUint32), uint64(math.MaxUint64), uintptr(12),
+		float32(0.1), float32(-3.5e20), float64(1) / 3, math.Inf(-1), math.NaN(), 1e21, 1e-7, float64(100),
+	}
+	for _, v := range tests {
+		t.Run(fmt.Sprintf("%T(%v)", v, v), func(t *testing.T) {
+			data := reflect.ValueOf(v)
+			kind := plainKind(data.Type())
+			if kind == reflect.Invalid {
+				t.Fatalf("expected %T to be plain", v)
+			}
+			if want, actual := fmt.Sprintf("%v", v), plainString(data, kind); want != actual {
+				t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+			}
+		})
+	}
+
+	t.Run("not plain", func(t *testing.T) {
+		for _, typ := range []reflect.Type{
+			reflect.TypeFor[time.Duration](), // Stringer
+			reflect.TypeFor[any](),
+			reflect.TypeFor[[]int](),
+			reflect.TypeFor[complex128](),
+			reflect.TypeFor[struct{ A int }](),
+		} {
+			if kind := plainKind(typ); kind != reflect.Invalid {
+				t.Errorf("expected %v to not be plain, got %v", typ, kind)
+			}
+		}
+		if kind := plainKind(reflect.TypeFor[**int]()); kind != reflect.Int {
+			t.Errorf("expected pointers to be dereferenced, got %v", kind)
+		}
+	})
+}
+
+// benchmarks suffixed Uncached clear schemaCache before every operation, so
+// columns are resolved anew each time, as they were prior to caching schemas.
+
+// the common API server case: many small calls rendering the same type
+func BenchmarkToCSV(b *testing.B) {
+	recs := benchRecords(10)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ToCSV(recs, benchColumns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToCSVUncached(b *testing.B) {
+	recs := benchRecords(10)
+	b.ReportAllocs()
+	for b.Loop() {
+		schemaCache.Clear()
+		if _, err := ToCSV(recs, benchColumns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToJSON(b *testing.B) {
+	recs := benchRecords(10)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ToJSON(recs, benchColumns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToJSONUncached(b *testing.B) {
+	recs := benchRecords(10)
+	b.ReportAllocs()
+	for b.Loop() {
+		schemaCache.Clear()
+		if _, err := ToJSON(recs, benchColumns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToTable(b *testing.B) {
+	recs := benchRecords(10)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ToTable(recs, benchColumns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToTableUncached(b *testing.B) {
+	recs := benchRecords(10)
+	b.ReportAllocs()
+	for b.Loop() {
+		schemaCache.Clear()
+		if _, err := ToTable(recs, benchColumns); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// streaming a large number of records; per-cell costs dominate
+func BenchmarkCSVEncoder(b *testing.B) {
+	recs := benchRecords(1000)
+	b.ReportAllocs()
+	for b.Loop() {
+		enc := NewCSVEncoder(io.Discard, benchColumns)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONEncoder(b *testing.B) {
+	recs := benchRecords(1000)
+	b.ReportAllocs()
+	for b.Loop() {
+		enc := NewJSONEncoder(io.Discard, benchColumns, JSONLines)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -6,9 +6,12 @@
 package weave
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/Jeffail/gabs/v2"
@@ -18,9 +21,40 @@ import (
 
 //#region errors
 
-const (
-	ErrNotAStruct  string = "given value is not a struct or pointer to a struct"
-	ErrStructIsNil string = "given value is nil"
+// Sentinel errors returned (generally wrapped) by the output modules and
+// helpers. Test for them with errors.Is.
+var (
+	// the given delimiter or quote character is invalid
+	ErrInvalidDelimiter = errors.New("invalid delimiter or quote character")
+	// a column (or the root or record element) is not a valid XML name
+	ErrInvalidXMLName = errors.New("invalid XML name")
+	// multiple columns would be output as the same XML element or attribute, or
+	// one as an element containing another's
+	ErrDuplicateXMLName = errors.New("duplicate XML name")
+	// the given value (or record) is not a struct
+	ErrNotAStruct = errors.New("given value is not a struct or pointer to a struct")
+	// the given value (or record) is nil
+	ErrStructIsNil = errors.New("given value is nil")
+	// a requested column does not resolve to a field
+	ErrUnknownColumn = errors.New("column does not match any field")
+	// a requested column matches multiple fields
+	ErrAmbiguousColumn = errors.New("column matches multiple fields")
+	// a requested field is unexported and its value cannot be output
+	ErrUnexportedField = errors.New("field is unexported")
+	// a record is not of the same type as the first record
+	ErrMismatchedRecord = errors.New("record type does not match prior records")
+	// the encoder was used after being closed
+	ErrEncoderClosed = errors.New("encoder is closed")
+	// a column expected to be a map is not a map with string keys
+	ErrNotAMap = errors.New("field is not a map with string keys")
+	// a map key cannot be addressed by a qualified name (ex: it contains a ".")
+	ErrUnqualifiableKey = errors.New("map key cannot be qualified")
+	// a column expected to be a slice is not a slice or array
+	ErrNotASlice = errors.New("field is not a slice or array")
+	// an index or wildcard column was not given an alias to output it as
+	ErrUnaliasedColumn = errors.New("index and wildcard columns require an alias")
+	// the output path of a column overwrites or is nested beneath another's
+	ErrColumnCollision = errors.New("column output path collides with another column")
 )
 
 //#endregion
@@ -28,113 +62,324 @@ const (
 // Takes an array of arbitrary struct `st` and the *ordered* columns to
 // include/exclude and returns a string containing the csv representation of the
 // data contained therein.
+// Headers and fields are quoted and escaped per RFC 4180.
+// See ToDelimited for other delimiters (ex: TSV).
 //
-// ! Returns the empty string if columns or st are empty
-func ToCSV[Any any](st []Any, columns []string) string {
-	// DESIGN:
-	// We have a list of column, ordered.
-	// We have a map of column names -> field index.
-	// For each struct s in the list of structs:
-	//	iterate through the list of columns and use the map to fetch the
-	//	column/field's values by index, building the csv token by token
+// Columns may be given as qualified names or as Column specs.
+//
+// ! Returns the empty string (and no error) if columns or st are empty
+func ToCSV[Any any, C Columns](st []Any, columns C, opts ...Option) (string, error) {
+	return ToDelimited(st, columns, ',', opts...)
+}
 
-	if columns == nil || st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
-		return ""
+// Takes an array of arbitrary struct `st` and the qualified columns to
+// *exclude* and returns a string containing the csv representation of every
+// other exported field (as given by StructFields), in struct order.
+// Excluding a struct excludes all of its descendants (ex: excluding "Auth"
+// excludes "Auth.Token" and "Auth.User").
+//
+// Blacklisted columns that do not resolve to a field are ignored, unless
+// Strict is given.
+//
+// ! Returns the empty string (and no error) if st is empty or every column is
+// excluded
+func ToCSVExclude[Any any](st []Any, blacklist []string, opts ...Option) (string, error) {
+	if st == nil || len(st) < 1 { // superfluous request
+		return "", nil
 	}
 
-	// test the first struct is actually a struct
-	// if later structs do not match, that is a developer error
-	if reflect.TypeOf(st[0]).Kind() != reflect.Struct {
-		return ""
+	t, err := recordsType(st)
+	if err != nil {
+		return "", err
+	}
+	columns, err := excludeColumns(t, blacklist, true, newOptions(opts))
+	if err != nil {
+		return "", err
 	}
 
-	columnMap := buildColumnMap(st[0], columns)
+	return ToCSV(st, columns, opts...)
+}
 
-	var hdr string = strings.Join(columns, ",")
+// helper function for ToCSV and CSVEncoder
+// writes the CSV row populated by the data in the struct (and exploded
+// element) that corresponds to the columns, sans line terminator.
+// An invalid (nil) structVals writes a row of null values.
+func writeStructCSV(w *bufio.Writer, structVals, elem reflect.Value, r *resolver, d *dialect) {
+	// DESIGN:
+	// Columns were resolved (by position) against the record's type once, by
+	// the resolver.
+	// Iterate through the columns, fetching each field's value by its resolved
+	// path and building the row token by token.
+	// search for each column
+	for i := range r.columns {
+		if i > 0 {
+			w.WriteRune(d.delimiter) // separate from prior token
+		}
+		if !structVals.IsValid() { // nil record
+			w.WriteString(d.escape(r.opts.nullRepr))
+			continue
+		}
+		cf := r.fields[i]
+		if cf == nil {
+			// no matching field
+			// do nothing
+			continue
+		}
+		w.WriteString(d.escape(stringifyField(source(structVals, elem, cf), cf, r.opts)))
+	}
+}
 
-	var csv strings.Builder // stores the actual data
+// fieldValue returns the value of the resolved field within the given struct.
+// Returns false, rather than panicking, if the field's path passes through a
+// nil pointer or map, a map key that is not present, or an element index that
+// is out of range.
+//
+// ! cf must not contain a wildcard; use fieldValues
+func fieldValue(structVals reflect.Value, cf *columnField) (data reflect.Value, ok bool) {
+	data = structVals
+	for _, step := range cf.steps {
+		if data, ok = indirect(data); !ok || !data.IsValid() {
+			return reflect.Value{}, false
+		}
+		switch step.kind {
+		case stepField:
+			var err error
+			if data, err = data.FieldByIndexErr(step.index); err != nil { // traversed a nil pointer
+				return reflect.Value{}, false
+			}
+		case stepKey:
+			if data = data.MapIndex(step.key); !data.IsValid() { // key not present
+				return reflect.Value{}, false
+			}
+		case stepElem:
+			if step.elem >= data.Len() { // out of range
+				return reflect.Value{}, false
+			}
+			data = data.Index(step.elem)
+		}
+	}
+	return data, data.IsValid()
+}
 
-	for _, s := range st { // operate on each struct'
-		csv.WriteString(stringifyStructCSV(s, columns, columnMap) + "\n")
+// fieldValues returns the values of the resolved field within the given
+// struct, one for each element matched by the wildcards in its path (in
+// order). Multiple wildcards are flattened into a single list.
+// As with fieldValue, values that cannot be reached are returned invalid.
+func fieldValues(structVals reflect.Value, cf *columnField) []reflect.Value {
+	var values []reflect.Value
+	walkSteps(structVals, cf.steps, func(v reflect.Value, _ bool) {
+		values = append(values, v)
+	})
+	return values
+}
+
+// walkSteps traverses the given steps from data, calling visit with each value
+// reached (and whether it could be reached).
+// visit is called exactly once unless the steps contain a wildcard, in which
+// case it is called once per element matched (if any).
+func walkSteps(data reflect.Value, steps []pathStep, visit func(data reflect.Value, ok bool)) {
+	for i, step := range steps {
+		var ok bool
+		if data, ok = indirect(data); !ok || !data.IsValid() {
+			visit(reflect.Value{}, false)
+			return
+		}
+		switch step.kind {
+		case stepField:
+			var err error
+			if data, err = data.FieldByIndexErr(step.index); err != nil { // traversed a nil pointer
+				visit(reflect.Value{}, false)
+				return
+			}
+		case stepKey:
+			if data = data.MapIndex(step.key); !data.IsValid() { // key not present
+				visit(reflect.Value{}, false)
+				return
+			}
+		case stepElem:
+			if step.elem >= data.Len() { // out of range
+				visit(reflect.Value{}, false)
+				return
+			}
+			data = data.Index(step.elem)
+		case stepWildcard:
+			for k := 0; k < data.Len(); k++ {
+				walkSteps(data.Index(k), steps[i+1:], visit)
+			}
+			return
+		}
 	}
+	visit(data, data.IsValid())
+}
 
-	return strings.TrimSpace(hdr + "\n" + csv.String())
+// indirect dereferences the given value through any pointers and interfaces.
+// Returns false if a nil pointer or interface is encountered or if the chain of
+// pointers is cyclic (ex: an interface holding a pointer to itself).
+func indirect(data reflect.Value) (reflect.Value, bool) {
+	var guard cycleGuard
+	for data.Kind() == reflect.Pointer || data.Kind() == reflect.Interface {
+		if data.IsNil() || guard.cyclic(data) {
+			return reflect.Value{}, false
+		}
+		data = data.Elem()
+	}
+	return data, true
 }
 
-// helper function for ToCSVHash
-// returns a string of a CSV row populated by the data in the struct that corresponds to the columns
-func stringifyStructCSV(s interface{}, columns []string, columnMap map[string][]int) string {
-	var row strings.Builder
+// cycleGuard detects cycles while following a chain of pointers.
+// As chains of more than a few pointers are rare, pointers are only tracked
+// once the chain exceeds guardAfter, keeping the common case allocation-free.
+type cycleGuard struct {
+	steps int
+	seen  []uintptr
+}
 
-	// deconstruct the struct
-	structVals := reflect.ValueOf(s)
+// guardAfter is the length of a chain of pointers after which cycleGuard
+// begins tracking.
+const guardAfter = 8
 
-	// search for each column
-	for _, col := range columns {
-		findices := columnMap[col]
-		if findices == nil {
-			// no matching field
-			// do nothing
-		} else {
-			// use field index to retrieve value
-			data := structVals.FieldByIndex(findices)
-			if data.Kind() == reflect.Pointer {
-				data = data.Elem()
-			}
-			row.WriteString(fmt.Sprintf("%v", data))
+// cyclic returns whether the given value is a pointer that has already been
+// followed.
+func (g *cycleGuard) cyclic(v reflect.Value) bool {
+	if g.steps++; g.steps <= guardAfter || v.Kind() != reflect.Pointer {
+		return false
+	}
+	p := v.Pointer()
+	if slices.Contains(g.seen, p) {
+		return true
+	}
+	g.seen = append(g.seen, p)
+	return false
+}
+
+// stringifyField returns the string representation of the resolved field
+// within the given struct for tabular output.
+// Nil values are represented by o.nullRepr.
+// The values of a wildcard field are joined by o.joiner.
+func stringifyField(structVals reflect.Value, cf *columnField, o *options) string {
+	if cf.wildcard {
+		values := fieldValues(structVals, cf)
+		strs := make([]string, len(values))
+		for i, data := range values {
+			strs[i] = stringifyValue(data, cf, o)
 		}
-		row.WriteString(",") // append comma to token
+		return strings.Join(strs, o.joiner)
 	}
+	data, _ := fieldValue(structVals, cf)
+	return stringifyValue(data, cf, o)
+}
 
-	return strings.TrimSuffix(row.String(), ",")
+// stringifyValue returns the string representation of the given value of the
+// given column for tabular output, as given by the first applicable of: a
+// Formatter, encoding.TextMarshaler, fmt.Stringer, driver.Valuer, or fmt's %v.
+// Nil (and invalid) values are represented by o.nullRepr.
+func stringifyValue(data reflect.Value, cf *columnField, o *options) string {
+	if cf.plain != reflect.Invalid && cf.format == nil && len(o.formatters) == 0 { // fast path
+		data, ok := indirect(data)
+		if !ok || !data.IsValid() {
+			return o.nullRepr
+		}
+		return plainString(data, cf.plain)
+	}
+	if s, ok := format(data, cf, o); ok {
+		return s
+	}
+	if s, ok := marshalText(data); ok {
+		return s
+	}
+	if v, ok := driverValue(data); ok {
+		return stringifyValue(v, cf, o)
+	}
+	data, ok := indirect(data)
+	if !ok || !data.IsValid() {
+		return o.nullRepr
+	}
+	switch data.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		// fmt would print nested pointers as addresses
+		return printValue(data)
+	}
+	return fmt.Sprintf("%v", data)
 }
 
 // Given an array of an arbitrary struct and the list of *fully-qualified* fields,
 // outputs a table containing the data in the array of the struct.
 //
-// Can optionally be given a table style func. Uses DefaultTblStyle() if not given.
-func ToTable[Any any](st []Any, columns []string, styleFunc ...func() *table.Table) string {
-	if columns == nil || st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
-		return ""
+// Can optionally be given a table style func via TableStyle. Uses
+// DefaultTblStyle() if not given.
+//
+// Columns may be given as qualified names or as Column specs.
+//
+// ! Returns the empty string (and no error) if columns or st are empty
+func ToTable[Any any, C Columns](st []Any, columns C, opts ...Option) (string, error) {
+	if st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
+		return "", nil
 	}
 
-	columnMap := buildColumnMap(st[0], columns)
+	o := newOptions(opts)
+	r := resolver{columns: toColumns(columns), opts: o}
 
-	var rows [][]string = make([][]string, len(st))
-
-	for i := range st { // operate on each struct
-		rows[i] = make([]string, len(columns))
-		// deconstruct the struct
-		structVals := reflect.ValueOf(st[i])
-		// search for each column
-		for k := range columns {
-			findex := columnMap[columns[k]]
-			if findex != nil {
-				data := structVals.FieldByIndex(findex)
-				if data.Kind() == reflect.Pointer {
-					data = data.Elem()
-				}
-				// save the data into our row
-				rows[i][k] = fmt.Sprintf("%v", data)
-			}
-		}
+	rows, _, err := tableRows(st, &r)
+	if err != nil {
+		return "", err
 	}
 
 	var tbl *table.Table
 	// if user supplied a tableStyle, use it. Otherwise, use the default
-	if len(styleFunc) > 0 {
-		tbl = styleFunc[0]()
+	if o.tableStyle != nil {
+		tbl = o.tableStyle()
 	} else {
 		tbl = DefaultTblStyle()
 	}
 
-	tbl.Headers(columns...)
+	tbl.Headers(columnNames(r.columns)...)
 	tbl.Rows(rows...)
 
-	return tbl.Render()
+	return tbl.Render(), nil
 }
 
-// Style function used internally by ToTable if a styleFunc is not provided.
+// helper function for the tabular output modules (ToTable, ToMarkdown, ...)
+// returns the stringified cells of each row, in column order, and the index
+// within st of the record each row was output from.
+// Nil records are output as a row of null values, unless skipped; exploded
+// records are output as a row per element.
+func tableRows[Any any](st []Any, r *resolver) (rows [][]string, records []int, err error) {
+	rows = make([][]string, 0, len(st))
+	records = make([]int, 0, len(st))
+
+	for i := range st { // operate on each struct
+		structVals, err := r.resolve(st[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		if !structVals.IsValid() { // nil record
+			if r.opts.skipNil {
+				continue
+			}
+			row := make([]string, len(r.columns))
+			for k := range row {
+				row[k] = r.opts.nullRepr
+			}
+			rows, records = append(rows, row), append(records, i)
+			continue
+		}
+		for _, elem := range r.elements(structVals) {
+			row := make([]string, len(r.columns))
+			// search for each column
+			for k := range r.columns {
+				cf := r.fields[k]
+				if cf != nil {
+					// save the data into our row
+					row[k] = stringifyField(source(structVals, elem, cf), cf, r.opts)
+				}
+			}
+			rows, records = append(rows, row), append(records, i)
+		}
+	}
+	return rows, records, nil
+}
+
+// Style function used internally by ToTable if a TableStyle is not provided.
 // Use as an example for supplying your own.
 func DefaultTblStyle() *table.Table {
 	return table.New().StyleFunc(func(row, col int) lipgloss.Style {
@@ -152,132 +397,279 @@ type gComplex[t float32 | float64] struct {
 // Given an array of an arbitrary struct and the list of *fully-qualified* fields,
 // outputs a JSON array containing the data in the array of the struct.
 // Output is sorted alphabetically
-func ToJSON[Any any](st []Any, columns []string) (string, error) {
-	if columns == nil || st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
+//
+// Columns may be given as qualified names or as Column specs; an aliased
+// column is keyed (and nested) by its alias.
+// Index and wildcard columns (ex: "Hops.*.Addr") must be aliased, or
+// ErrUnaliasedColumn is returned. Columns whose keys would overwrite or nest
+// beneath one another return ErrColumnCollision.
+//
+// ! Returns an empty array (and no error) if columns or st are empty
+func ToJSON[Any any, C Columns](st []Any, columns C, opts ...Option) (string, error) {
+	if st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
 		return "[]", nil
 	}
 
-	columnMap := buildColumnMap(st[0], columns)
+	return encodeJSON(st, toColumns(columns), opts)
+}
 
+// helper function for ToJSON and ToJSONExclude
+// returns the JSON array of the given columns of every record.
+// Unlike ToJSON, no columns outputs an empty object per record.
+func encodeJSON[Any any](st []Any, columns []Column, opts []Option) (string, error) {
 	var bldr strings.Builder
-	bldr.WriteRune('[') // open JSON array
-	for _, s := range st {
-		g := gabs.New()
-		structVO := reflect.ValueOf(s)
-		for _, col := range columns {
-			// get value associated to this column
-			fIndex := columnMap[col]
-			if fIndex != nil {
-				data := structVO.FieldByIndex(fIndex)
-				if data.Kind() == reflect.Pointer {
-					data = data.Elem()
+	enc := NewJSONEncoder(&bldr, columns, JSONArray, opts...)
+	for i, s := range st {
+		if err := enc.Encode(s); err != nil {
+			return "", fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	return bldr.String(), nil
+}
+
+// helper function for ToJSON and JSONEncoder
+// returns a JSON object populated by the data in the struct (and exploded
+// element) that corresponds to the columns, nested by qualification
+func structToJSON(structVO, elem reflect.Value, r *resolver) (*gabs.Container, error) {
+	g := gabs.New()
+	var nulled []string // nil struct pointers output as null
+	for _, ns := range r.nullStructs {
+		if slices.ContainsFunc(nulled, func(p string) bool { return strings.HasPrefix(ns.name, p+".") }) {
+			continue // beneath a null
+		}
+		if data, ok := fieldValue(structVO, ns.cf); ok {
+			if _, ok = indirect(data); ok {
+				continue
+			}
+		}
+		if _, err := g.SetP(nil, ns.name); err != nil {
+			return nil, fmt.Errorf("column %q: %w", ns.name, err)
+		}
+		nulled = append(nulled, ns.name)
+	}
+	for i, column := range r.columns {
+		col := column.Name() // output path
+		if len(nulled) > 0 && slices.ContainsFunc(nulled, func(p string) bool { return strings.HasPrefix(col, p+".") }) {
+			continue // output as part of a null struct
+		}
+		// get value associated to this column
+		cf := r.fields[i]
+		if cf == nil {
+			if r.opts.heterogeneous { // this type lacks the column
+				if _, err := g.SetP(nil, col); err != nil {
+					return nil, fmt.Errorf("column %q: %w", col, err)
 				}
-				switch data.Type().Kind() {
-				case reflect.Float32:
-					v := data.Interface().(float32)
-					g.SetP(v, col)
-				case reflect.Float64:
-					v := data.Interface().(float64)
-					g.SetP(v, col)
-				case reflect.Int:
-					v := data.Interface().(int)
-					g.SetP(v, col)
-				case reflect.Int8:
-					v := data.Interface().(int8)
-					g.SetP(v, col)
-				case reflect.Int16:
-					v := data.Interface().(int16)
-					g.SetP(v, col)
-				case reflect.Int32:
-					v := data.Interface().(int32)
-					g.SetP(v, col)
-				case reflect.Int64:
-					v := data.Interface().(int64)
-					g.SetP(v, col)
-				case reflect.Complex64:
-					v := data.Interface().(complex64)
-					gC := gComplex[float32]{Real: real(v), Imaginary: imag(v)}
-					if _, err := g.SetP(gC, col); err != nil {
-						return "", err
-					}
-				case reflect.Complex128:
-					v := data.Interface().(complex128)
-					gC := gComplex[float64]{Real: real(v), Imaginary: imag(v)}
-					if _, err := g.SetP(gC, col); err != nil {
-						return "", err
-					}
-				case reflect.Array, reflect.Slice:
-					// arrays must be iterated through and rebuilt to retain
-					// proper typing
-					g.ArrayP(col)
-					// append each item in the array
-					iCount := data.Len()
-					for i := 0; i < iCount; i++ {
-						g.ArrayAppendP(data.Index(i).Interface(), col)
-					}
-				case reflect.Uint:
-					v := data.Interface().(uint)
-					g.SetP(v, col)
-				case reflect.Uint8:
-					v := data.Interface().(uint8)
-					g.SetP(v, col)
-				case reflect.Uint16:
-					v := data.Interface().(uint16)
-					g.SetP(v, col)
-				case reflect.Uint32:
-					v := data.Interface().(uint32)
-					g.SetP(v, col)
-				case reflect.Uint64:
-					v := data.Interface().(uint64)
-					g.SetP(v, col)
-				case reflect.String:
-					v := data.Interface().(string)
-					g.SetP(v, col)
-				default: // unsupported type, default to string
-					g.SetP(fmt.Sprintf("%v", data), col)
+			}
+			continue
+		}
+		if cf.indexed && column.Alias == "" { // would key objects by index or "*"
+			return nil, fmt.Errorf("column %q: %w", col, ErrUnaliasedColumn)
+		}
+		src := source(structVO, elem, cf)
+		if cf.wildcard {
+			values := fieldValues(src, cf)
+			if cf.omitEmpty && len(values) == 0 {
+				continue
+			}
+			arr := make([]any, len(values))
+			for i, data := range values {
+				if data.IsValid() && !data.CanInterface() {
+					return nil, fmt.Errorf("column %q: %w", col, ErrUnexportedField)
 				}
+				v, err := jsonValue(data, cf, r.opts)
+				if err != nil {
+					return nil, fmt.Errorf("column %q: %w", col, err)
+				}
+				arr[i] = v
+			}
+			if _, err := g.SetP(arr, col); err != nil {
+				return nil, fmt.Errorf("column %q: %w", col, err)
 			}
+			continue
+		}
+		data, ok := fieldValue(src, cf)
+		if ok && !data.CanInterface() {
+			return nil, fmt.Errorf("column %q: %w", col, ErrUnexportedField)
+		}
+		if cf.omitEmpty && (!ok || isEmptyValue(data)) {
+			continue
+		}
+		v, err := jsonValue(data, cf, r.opts)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", col, err)
+		}
+		if _, err := g.SetP(v, col); err != nil {
+			return nil, fmt.Errorf("column %q: %w", col, err)
 		}
-		bldr.WriteString(g.String())
-		bldr.WriteRune(',') // new entry
 	}
-	toRet := strings.TrimSuffix(bldr.String(), ",") // chomp final comma
+	return g, nil
+}
 
-	return toRet + "]", nil // close JSON array
+// jsonCollision returns ErrColumnCollision if the output path of a column
+// would overwrite, or be nested beneath, that of another (ex: "A" and "A.B"),
+// regardless of their order.
+func jsonCollision(columns []Column) error {
+	names := columnNames(columns)
+	for i, a := range names {
+		for _, b := range names[:i] {
+			if a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".") {
+				return fmt.Errorf("column %q: %w: %q", a, ErrColumnCollision, b)
+			}
+		}
+	}
+	return nil
+}
+
+// jsonValue returns the given value of the given column in a form gabs can
+// output with proper typing.
+// Values formatted by a Formatter are returned as strings. Otherwise, values
+// implementing json.Marshaler or encoding.TextMarshaler are marshaled by it
+// (any error in doing so is returned) and values implementing driver.Valuer
+// are represented by the value it returns.
+// Nil (and invalid) values are returned as nil, to be output as null.
+func jsonValue(data reflect.Value, cf *columnField, o *options) (any, error) {
+	if cf.plain == reflect.Invalid || cf.format != nil || len(o.formatters) > 0 { // not fast path
+		if s, ok := format(data, cf, o); ok {
+			return s, nil
+		}
+		if v, ok, err := marshalJSON(data); ok {
+			return v, err
+		}
+		if v, ok := driverValue(data); ok {
+			return jsonValue(v, cf, o)
+		}
+	}
+	data, ok := indirect(data)
+	if !ok || !data.IsValid() {
+		return nil, nil
+	}
+	// leaf structs marshaling to neither JSON nor text are represented by
+	// their String, as they are in tabular output (ex: url.URL)
+	if data.Kind() == reflect.Struct && o.isLeaf(data.Type()) {
+		if v, ok := implementer(data, stringerType); ok {
+			return v.Interface().(fmt.Stringer).String(), nil
+		}
+	}
+	switch data.Type().Kind() {
+	case reflect.Float32:
+		return float32(data.Float()), nil
+	case reflect.Float64:
+		return data.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return data.Int(), nil
+	case reflect.Complex64:
+		v := data.Complex()
+		return gComplex[float32]{Real: float32(real(v)), Imaginary: float32(imag(v))}, nil
+	case reflect.Complex128:
+		v := data.Complex()
+		return gComplex[float64]{Real: real(v), Imaginary: imag(v)}, nil
+	case reflect.Array, reflect.Slice:
+		if isCyclic(data) {
+			return acyclicJSON(data, make(map[refKey]bool)), nil
+		}
+		// arrays must be iterated through and rebuilt to retain
+		// proper typing
+		iCount := data.Len()
+		arr := make([]any, iCount)
+		for i := 0; i < iCount; i++ {
+			arr[i] = data.Index(i).Interface()
+		}
+		return arr, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return data.Uint(), nil
+	case reflect.String:
+		return data.String(), nil
+	case reflect.Map, reflect.Interface, reflect.Struct:
+		// marshaled by encoding/json (via gabs) as objects
+		if isCyclic(data) { // ex: a truncated recursive type (see StructFields)
+			return acyclicJSON(data, make(map[refKey]bool)), nil
+		}
+		return data.Interface(), nil
+	default: // unsupported type, default to string
+		return fmt.Sprintf("%v", data), nil
+	}
 }
 
-// BROKEN UNTIL Gabs ISSUE#141 IS RESOLVED
 // Given an array of an arbitrary struct, outputs a JSON array containing the
-// data in the array of the struct, minus the blacklisted columns
+// data in the array of the struct, minus the blacklisted columns.
 // Output is sorted alphabetically
-func ToJSONExclude[Any any](st []Any, blacklist []string) (string, error) {
+//
+// Every exported field (as given by StructFields) is output except those
+// blacklisted. Excluding a struct excludes its entire sub-object.
+// A nil pointer to a struct none of whose fields are excluded is output as
+// null; otherwise, its remaining fields are output as null.
+// If every field is excluded, each record is output as an empty object.
+// Blacklisted columns that do not resolve to a field are ignored, unless
+// Strict is given.
+func ToJSONExclude[Any any](st []Any, blacklist []string, opts ...Option) (string, error) {
 	if st == nil || len(st) < 1 { // superfluous request
-		return "[]", errors.New(ErrStructIsNil)
+		return "[]", ErrStructIsNil
 	}
 
-	// test the first struct is actually a struct
-	// if later structs do not match, that is a developer error
-	if reflect.TypeOf(st[0]).Kind() != reflect.Struct {
-		return "[]", errors.New(ErrNotAStruct)
+	// columns are determined by the first non-nil record
+	// if later records do not match, ToJSON returns ErrMismatchedRecord
+	t, err := recordsType(st)
+	if err != nil {
+		return "[]", err
 	}
 
-	var writer strings.Builder
-	writer.WriteRune('[')
-	for _, s := range st {
-		obj := gabs.Wrap(s)
-		// remove excluded colums
-		/*for _, col := range blacklist {
-			if err := obj.DeleteP(col); err != nil {
-				return "", fmt.Errorf("column %s: %v", col, err)
-			}
-		}*/
-		// add to array
-		writer.WriteString(obj.String())
-		writer.WriteString(",")
+	o := newOptions(opts)
+	columns, err := excludeColumns(t, blacklist, true, o)
+	if err != nil {
+		return "[]", err
 	}
+	// output nil struct pointers as null, rather than as an object of nulls,
+	// wherever the entire struct is output
+	nulls := wholeStructPointers(t, structFields(t, true, o), columns, o)
+	opts = append(slices.Clip(opts), func(o *options) {
+		o.nullStructs = nulls
+	})
+
+	return encodeJSON(st, toColumns(columns), opts)
+}
 
-	// chip, close, and return
-	return strings.TrimSuffix(writer.String(), ",") + "]", nil
+// wholeStructPointers returns the qualified names of the struct pointer fields
+// of t every descendant of which (as given by all) is in columns, in struct
+// order.
+//
+// ! t must be a struct type
+func wholeStructPointers(t reflect.Type, all, columns []string, o *options) []string {
+	included := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		included[col] = true
+	}
+	var (
+		pointers []string
+		seen     = make(map[string]bool)
+	)
+	for _, col := range all {
+		for i := range col {
+			if col[i] != '.' || seen[col[:i]] {
+				continue
+			}
+			parent := col[:i]
+			seen[parent] = true
+			field, _, found, err := resolvePath(parent, t, o)
+			if err != nil || !found || field.Type.Kind() != reflect.Pointer {
+				continue
+			}
+			whole := true
+			for _, c := range all {
+				if strings.HasPrefix(c, parent+".") && !included[c] {
+					whole = false
+					break
+				}
+			}
+			if whole {
+				pointers = append(pointers, parent)
+			}
+		}
+	}
+	return pointers
 }
 
 // Given a fully qualified column name (ex: "outerstruct.innerstruct.field"),
@@ -289,67 +681,308 @@ func ToJSONExclude[Any any](st []Any, blacklist []string) (string, error) {
 // Returns the field, whether or not it was found, the index path (for
 // FieldByIndex) to the field (more on this below), and any errors.
 //
+// If CaseInsensitive is given, each qualification is matched
+// case-insensitively, preferring an exact match. If multiple fields differ from
+// the qualification only by case, returns ErrAmbiguousColumn.
+//
+// If Tags is given, each qualification is matched against field names as
+// given by their struct tags; fields tagged "-" cannot be found.
+//
+// A qualification following a map field with string keys is a key of that
+// map (ex: "Labels.env"); it is matched exactly, regardless of options.
+// A qualification following a slice or array field is either an element index
+// (ex: "Hops.0.Addr") or "*", matching every element (ex: "Hops.*.Addr").
+// As there is no struct field for a map key or element, field is synthesized
+// with the qualification as its Name and the element type as its Type, and
+// index is nil (as FieldByIndex cannot traverse maps or slices).
+//
 // ! st must be a struct
-func FindQualifiedField[Any any](qualCol string, st any) (field reflect.StructField, found bool, index []int, err error) {
-	// Design Note:
-	// Index path is returned becaue field.Index is NOT reliable for some
-	// nested fields. Fields do not necessarily know their complete index path
-	// for the given parent struct and therefore using field.Index in FieldByIndex
-	// can cause unexpected, erroneous reults (generally fetching items at a
-	// higher depth than the field actually is).
-	// The returned index path is composed of the known indices of every field
-	// touched during traversal, returning a complete path.
-
+func FindQualifiedField[Any any](qualCol string, st any, opts ...Option) (field reflect.StructField, found bool, index []int, err error) {
 	// pre checks
 	if qualCol == "" {
 		return reflect.StructField{}, false, nil, nil
 	}
 	if st == nil {
-		return reflect.StructField{}, false, nil, errors.New(ErrStructIsNil)
+		return reflect.StructField{}, false, nil, ErrStructIsNil
 	}
 	t := reflect.TypeOf(st)
 	if t.Kind() != reflect.Struct {
-		return reflect.StructField{}, false, nil, errors.New(ErrNotAStruct)
+		return reflect.StructField{}, false, nil, ErrNotAStruct
 	}
 
-	index = make([]int, 0)
+	return findQualifiedField(qualCol, t, newOptions(opts))
+}
+
+// findQualifiedField is FindQualifiedField, given the struct's type and
+// already-built options.
+//
+// ! t must be a struct type
+func findQualifiedField(qualCol string, t reflect.Type, o *options) (field reflect.StructField, found bool, index []int, err error) {
+	field, steps, found, err := resolvePath(qualCol, t, o)
+	if !found || err != nil {
+		return reflect.StructField{}, false, nil, err
+	}
+	cf := columnField{steps: steps}
+	index, _ = cf.fieldIndex()
+	return field, true, index, nil
+}
+
+// resolvePath resolves the qualified column name against the struct type t,
+// returning the final field and the steps to traverse to reach it from a value
+// of type t.
+//
+// ! t must be a struct type
+func resolvePath(qualCol string, t reflect.Type, o *options) (field reflect.StructField, steps []pathStep, found bool, err error) {
+	// Design Note:
+	// Index path is built rather than using field.Index becaue field.Index is
+	// NOT reliable for some nested fields. Fields do not necessarily know their
+	// complete index path for the given parent struct and therefore using
+	// field.Index in FieldByIndex can cause unexpected, erroneous reults
+	// (generally fetching items at a higher depth than the field actually is).
+	// The built index path is composed of the known indices of every field
+	// touched during traversal, returning a complete path.
+	// Consecutive fields are merged into a single step.
+
+	if qualCol == "" {
+		return reflect.StructField{}, nil, false, nil
+	}
 
 	exploded := strings.Split(qualCol, ".")
 	field.Type = t
 	// iterate down the field tree until we run out of qualifications or cannot
 	// locate the next qualification
 	for _, e := range exploded {
-		if field.Type.Kind() == reflect.Pointer {
-			field.Type = field.Type.Elem() // dereference
-		}
-		field, found = field.Type.FieldByName(e)
-		if !found { // no value found
-			//fmt.Printf("Found no value for qualifier '%s' at depth %d\n", e, i)
-			return reflect.StructField{}, false, nil, nil
+		parent := derefType(field.Type)
+		switch {
+		case parent.Kind() == reflect.Struct:
+			field, found, err = fieldByName(parent, e, o)
+			if err != nil {
+				return reflect.StructField{}, nil, false, err
+			}
+			if !found { // no value found
+				return reflect.StructField{}, nil, false, nil
+			}
+			// build path
+			if last := len(steps) - 1; last >= 0 && steps[last].kind == stepField {
+				steps[last].index = slices.Concat(steps[last].index, field.Index)
+			} else {
+				steps = append(steps, pathStep{kind: stepField, index: slices.Clone(field.Index)})
+			}
+		case parent.Kind() == reflect.Map && parent.Key().Kind() == reflect.String:
+			steps = append(steps, pathStep{kind: stepKey, key: reflect.ValueOf(e).Convert(parent.Key())})
+			field = reflect.StructField{Name: e, Type: parent.Elem()}
+		case parent.Kind() == reflect.Slice || parent.Kind() == reflect.Array:
+			if e == "*" {
+				steps = append(steps, pathStep{kind: stepWildcard})
+			} else if i, aErr := strconv.Atoi(e); aErr == nil && i >= 0 &&
+				(parent.Kind() == reflect.Slice || i < parent.Len()) {
+				steps = append(steps, pathStep{kind: stepElem, elem: i})
+			} else { // not an index
+				return reflect.StructField{}, nil, false, nil
+			}
+			field = reflect.StructField{Name: e, Type: parent.Elem()}
+		default: // qualified beyond a leaf
+			return reflect.StructField{}, nil, false, nil
 		}
-		// build path
-		index = append(index, field.Index...)
 	}
 	// if we reached the end of the loop, we have our final field
-	return field, true, index, nil
+	return field, steps, true, nil
+}
+
+// fieldByName is the equivalent of t.FieldByName, respecting the naming
+// options (CaseInsensitive, Tags).
+// As with FieldByName, the shallowest match (accounting for promotion) is
+// returned and multiple matches at that depth annihilate each other.
+// If case-insensitive, exact matches are preferred and ErrAmbiguousColumn is
+// returned if multiple, differently-cased fields match at that depth.
+func fieldByName(t reflect.Type, name string, o *options) (field reflect.StructField, found bool, err error) {
+	if !o.caseInsensitive && len(o.tagKeys) == 0 { // fast path
+		field, found = t.FieldByName(name)
+		return field, found, nil
+	}
 
+	field, found, _ = fieldByNameFunc(t, o, func(s string) bool { return s == name })
+	if found || !o.caseInsensitive {
+		return field, found, nil
+	}
+
+	field, found, matched := fieldByNameFunc(t, o, func(s string) bool { return strings.EqualFold(s, name) })
+	// differentiate annihilation by case from annihilation by promotion
+	if !found && len(matched) > 1 {
+		slices.Sort(matched)
+		return reflect.StructField{}, false, fmt.Errorf("%w: %q matches %q", ErrAmbiguousColumn, name, matched)
+	}
+	return field, found, nil
 }
 
+// fieldByNameFunc is the equivalent of t.FieldByNameFunc, naming each field per
+// fieldName.
+// Also returns the distinct names that matched at the shallowest matching
+// depth.
+func fieldByNameFunc(t reflect.Type, o *options, match func(string) bool) (field reflect.StructField, found bool, matched []string) {
+	type candidate struct {
+		typ   reflect.Type
+		index []int // path from t
+	}
+
+	// breadth-first, so shallower fields hide deeper, promoted fields
+	current := []candidate{{typ: t}}
+	visited := map[reflect.Type]bool{} // types explored at a shallower depth
+	for len(current) > 0 {
+		var (
+			next []candidate
+			hits []reflect.StructField
+		)
+		for _, c := range current {
+			if visited[c.typ] {
+				continue
+			}
+			for i := 0; i < c.typ.NumField(); i++ {
+				f := c.typ.Field(i)
+				name, _, hidden := fieldName(f, o)
+				if hidden {
+					continue
+				}
+				index := append(slices.Clone(c.index), i)
+				if match(name) {
+					if !slices.Contains(matched, name) {
+						matched = append(matched, name)
+					}
+					f.Index = index
+					hits = append(hits, f)
+					continue
+				}
+				if f.Anonymous { // explore promoted fields at the next depth
+					ft := f.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, candidate{typ: ft, index: index})
+					}
+				}
+			}
+		}
+		// types are marked only after the whole depth is explored so a type
+		// embedded multiple times at one depth annihilates its own fields
+		for _, c := range current {
+			visited[c.typ] = true
+		}
+
+		if len(hits) == 1 {
+			return hits[0], true, matched
+		} else if len(hits) > 1 {
+			return reflect.StructField{}, false, matched
+		}
+		current = next
+	}
+	return reflect.StructField{}, false, matched
+}
+
+//#region tags
+
+// fieldName returns the name of the given field, per the first of o.tagKeys
+// present on it.
+// Like encoding/json, a tag with an empty name uses the Go name, and a tag
+// consisting solely of "-" hides the field.
+// If no tag keys are present, the field's Go name is used.
+func fieldName(f reflect.StructField, o *options) (name string, omitEmpty, hidden bool) {
+	for _, key := range o.tagKeys {
+		tag, ok := f.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		if tag == "-" {
+			return "", false, true
+		}
+		name, tagOpts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		for _, opt := range strings.Split(tagOpts, ",") {
+			if opt == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		return name, omitEmpty, false
+	}
+	return f.Name, false, false
+}
+
+// isEmptyValue reports whether v is empty, per encoding/json's omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+//#endregion tags
+
 // Returns the fully qualified name of every (exported) field in the struct
 // *definition*, as they are ordered internally
 // These qualified names are the expected format for the output modules in this
 // package
-func StructFields(st any, exportedOnly bool) (columns []string, err error) {
+//
+// If Tags is given, fields are named by their struct tags and fields tagged
+// "-" are omitted.
+//
+// Recursive types (ex: `type Node struct { Next *Node }`) are descended into
+// only once; the field at which a type recurs is reported as a single field
+// ("Next"). If MaxDepth is given, recursive types are instead descended into
+// until that depth, as are all other structs.
+// Use StructFieldsTruncated to learn where descent was stopped.
+func StructFields(st any, exportedOnly bool, opts ...Option) (columns []string, err error) {
+	columns, _, err = StructFieldsTruncated(st, exportedOnly, opts...)
+	return columns, err
+}
+
+// StructFieldsTruncated is StructFields, additionally returning the qualified
+// names of the struct fields that were not descended into due to recursion or
+// MaxDepth (each of which is also a column).
+func StructFieldsTruncated(st any, exportedOnly bool, opts ...Option) (columns, truncated []string, err error) {
 	if st == nil {
-		return nil, errors.New(ErrStructIsNil)
+		return nil, nil, ErrStructIsNil
 	}
 	to := reflect.TypeOf(st)
 	if to.Kind() == reflect.Pointer { // dereference
 		to = to.Elem()
 	}
 	if to.Kind() != reflect.Struct { // prerequisite
-		return nil, errors.New(ErrNotAStruct)
+		return nil, nil, ErrNotAStruct
 	}
+	w := fieldWalk{exportedOnly: exportedOnly, o: newOptions(opts)}
+	columns = w.structFields(to)
+	return columns, w.truncated, nil
+}
+
+// structFields is StructFields, given the struct's type and already-built
+// options.
+//
+// ! to must be a struct type
+func structFields(to reflect.Type, exportedOnly bool, o *options) (columns []string) {
+	w := fieldWalk{exportedOnly: exportedOnly, o: o}
+	return w.structFields(to)
+}
+
+// fieldWalk is the state of a StructFields traversal.
+type fieldWalk struct {
+	exportedOnly bool
+	o            *options
+	path         []reflect.Type // struct types being descended into, outermost first
+	truncated    []string       // qualified names at which descent was stopped
+}
+
+// structFields returns the qualified names of every field in the given struct
+// type.
+func (w *fieldWalk) structFields(to reflect.Type) (columns []string) {
 	numFields := to.NumField()
 	columns = []string{}
 
@@ -357,73 +990,511 @@ func StructFields(st any, exportedOnly bool) (columns []string, err error) {
 	//	if the field is not a struct, append it to the columns
 	//	if the field is a struct, repeat
 
+	w.path = append(w.path, to)
 	for i := 0; i < numFields; i++ {
-		columns = append(columns, innerStructFields("", to.Field(i), exportedOnly)...)
+		columns = append(columns, w.innerStructFields("", to.Field(i))...)
 	}
+	w.path = w.path[:len(w.path)-1]
 
-	return columns, nil
+	return columns
 }
 
 // innerStructFields is a helper function for StructFields, returning the
 // qualified name of the given field or the list of qualified names of its
 // children, if a struct.
-// Operates recursively on the given field if it is a struct.
+// Operates recursively on the given field if it is a struct, unless doing so
+// would recurse into a type already being descended into or exceed the
+// maximum depth.
 // Operates down the struct, in field-order.
-func innerStructFields(qualification string, field reflect.StructField, exportedOnly bool) []string {
+func (w *fieldWalk) innerStructFields(qualification string, field reflect.StructField) []string {
 	var columns []string = []string{}
 
 	// do not operate on unexported fields if exportedOnly
-	if exportedOnly && !field.IsExported() {
+	if w.exportedOnly && !field.IsExported() {
 		return columns
 	}
 
+	name, _, hidden := fieldName(field, w.o)
+	if hidden {
+		return columns
+	}
+
+	qualName := name
+	if qualification != "" {
+		qualName = qualification + "." + name
+	}
+
 	// dereference
-	if field.Type.Kind() == reflect.Ptr {
-		field.Type = field.Type.Elem()
+	field.Type = derefType(field.Type)
+
+	// leaf types (see LeafTypes) are output as a single value
+	if field.Type.Kind() != reflect.Struct || w.o.isLeaf(field.Type) {
+		return append(columns, qualName)
 	}
 
-	if field.Type.Kind() == reflect.Struct {
-		for k := 0; k < field.Type.NumField(); k++ {
-			var innerQual string
-			if qualification == "" {
-				innerQual = field.Name
-			} else {
-				innerQual = qualification + "." + field.Name
+	// depth of the field's children
+	depth := strings.Count(qualName, ".") + 2
+	if (w.o.maxDepth > 0 && depth > w.o.maxDepth) ||
+		(w.o.maxDepth == 0 && slices.Contains(w.path, field.Type)) {
+		w.truncated = append(w.truncated, qualName)
+		return append(columns, qualName)
+	}
+
+	w.path = append(w.path, field.Type)
+	for k := 0; k < field.Type.NumField(); k++ {
+		columns = append(columns, w.innerStructFields(qualName, field.Type.Field(k))...)
+	}
+	w.path = w.path[:len(w.path)-1]
+
+	return columns
+}
+
+//#region maps
+
+// Given an array of arbitrary structs and the qualified name of a map field
+// with string keys, returns the qualified names of every key present in that
+// map across all records (ex: "Labels.env", "Labels.team"), sorted.
+// The names can be used as columns (or appended to other columns) to output
+// each key as its own column.
+//
+// Nil records and nil maps contribute no keys.
+// If Heterogeneous is given, records whose type lacks the column are skipped.
+//
+// Returns ErrUnknownColumn if the column does not resolve to a field or
+// ErrNotAMap if it is not a map with string keys.
+// Returns ErrUnqualifiableKey if a key contains a ".", as its qualified name
+// could not be resolved back to the key.
+func MapKeys[Any any](st []Any, qualCol string, opts ...Option) ([]string, error) {
+	o := newOptions(opts)
+	o.explode = "" // keys are gathered per record, not per element
+	r := resolver{columns: []Column{{Path: qualCol}}, opts: o}
+	keys := make(map[string]bool)
+	for i := range st {
+		structVals, err := r.resolve(st[i])
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		if !structVals.IsValid() { // nil record
+			continue
+		}
+		cf := r.fields[0]
+		if cf == nil {
+			if r.opts.heterogeneous {
+				continue
 			}
-			columns = append(columns, innerStructFields(innerQual, field.Type.Field(k), exportedOnly)...)
+			return nil, fmt.Errorf("column %q: %w", qualCol, ErrUnknownColumn)
 		}
-	} else {
-		if qualification == "" {
-			columns = append(columns, field.Name)
-		} else {
-			columns = append(columns, qualification+"."+field.Name)
+		if t := derefType(cf.typ); t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("column %q: %w", qualCol, ErrNotAMap)
+		}
+		data, ok := fieldValue(structVals, cf)
+		if ok {
+			data, ok = indirect(data)
+		}
+		if !ok {
+			continue
+		}
+		for iter := data.MapRange(); iter.Next(); {
+			key := iter.Key().String()
+			if strings.Contains(key, ".") {
+				return nil, fmt.Errorf("record %d: column %q: %w: %q", i, qualCol, ErrUnqualifiableKey, key)
+			}
+			keys[key] = true
 		}
 	}
 
-	return columns
+	columns := make([]string, 0, len(keys))
+	for k := range keys {
+		columns = append(columns, qualCol+"."+k)
+	}
+	slices.Sort(columns)
+	return columns, nil
+}
+
+//#endregion maps
+
+//#region columns
+
+// Column specifies a column for output, pairing the qualified name of a field
+// with the name to output it as.
+// Output modules accept a []Column in place of a []string of qualified names.
+type Column struct {
+	// fully qualified name of the field
+	Path string
+	// name to output the column as (in headers and as the JSON key); Path if
+	// empty.
+	// Like Path, an alias is dot qualified when output as JSON, so an alias of
+	// "Country" flattens "Source.Geo.Country" to a top-level key while an
+	// alias of "geo.country" nests it under "geo".
+	Alias string
+}
+
+// Name returns the name the column is output as.
+func (c Column) Name() string {
+	if c.Alias != "" {
+		return c.Alias
+	}
+	return c.Path
+}
+
+// Columns is the set of column types accepted by the output modules: either
+// fully qualified names or Column specs.
+//
+// ! As the column type is inferred, an untyped nil is not accepted; pass a
+// typed nil (ex: []string(nil)) instead
+type Columns interface {
+	[]string | []Column
 }
 
-// Given a struct and the desired fields (columns), maps the full, qualified
-// field names to their complete index chain. If a field is not found in the
-// struct, its value is set to nil in the map.
-func buildColumnMap(st any, columns []string) (columnMap map[string][]int) {
+// toColumns normalizes the given columns into Column specs.
+func toColumns[C Columns](columns C) []Column {
+	switch c := any(columns).(type) {
+	case []Column:
+		return c
+	case []string:
+		if c == nil {
+			return nil
+		}
+		cols := make([]Column, len(c))
+		for i := range c {
+			cols[i] = Column{Path: c[i]}
+		}
+		return cols
+	}
+	return nil
+}
+
+// columnPaths returns the qualified name of each column.
+func columnPaths(columns []Column) []string {
+	paths := make([]string, len(columns))
+	for i := range columns {
+		paths[i] = columns[i].Path
+	}
+	return paths
+}
+
+// columnNames returns the output name of each column.
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i := range columns {
+		names[i] = columns[i].Name()
+	}
+	return names
+}
+
+//#endregion columns
+
+// columnField is a column resolved to a field of a struct type
+type columnField struct {
+	steps     []pathStep   // traversal from the struct to the field
+	typ       reflect.Type // type of the field
+	omitEmpty bool         // tagged omitempty
+	wildcard  bool         // steps contain a wildcard; the field has many values
+	indexed   bool         // steps contain an element index or wildcard
+	exploded  bool         // steps are relative to an element of the exploded slice
+	plain     reflect.Kind // kind of the field if plain (see plainKind); otherwise Invalid
+	format    Formatter    // column formatter (see FormatColumn); nil if none
+}
+
+// stepKind is the kind of traversal a pathStep performs.
+type stepKind uint8
+
+const (
+	stepField    stepKind = iota // (possibly nested) struct field
+	stepKey                      // map key
+	stepElem                     // slice or array element
+	stepWildcard                 // every slice or array element
+)
+
+// pathStep is a single step of a resolved path.
+type pathStep struct {
+	kind  stepKind
+	index []int         // stepField; complete index chain (for FieldByIndex)
+	key   reflect.Value // stepKey
+	elem  int           // stepElem
+}
+
+// fieldIndex returns the complete index chain (for FieldByIndex) to the field.
+// Returns false if the path to the field passes through a map or slice.
+func (cf *columnField) fieldIndex() ([]int, bool) {
+	if len(cf.steps) != 1 || cf.steps[0].kind != stepField {
+		return nil, false
+	}
+	return cf.steps[0].index, true
+}
+
+// Given a struct type and the desired fields (columns), maps the full,
+// qualified field names to their resolved fields. If a field is not found in
+// the struct, its value is set to nil in the map.
+//
+// If o.explode, columns beneath the exploded slice are resolved against its
+// element type (see Explode).
+//
+// Returns an *UnknownColumnError if o.strict and any column could not be found.
+//
+// ! t must be a struct type
+func buildColumnMap(t reflect.Type, columns []string, o *options) (columnMap map[string]*columnField, err error) {
 	numColumns := len(columns)
 
+	var unknown []string // unresolved columns; only tracked if strict
+
+	var elemType reflect.Type // element type of the exploded slice, if any
+	explode, err := resolveExplode(t, o)
+	if err != nil {
+		return nil, err
+	} else if explode != nil {
+		elemType = derefType(explode.typ).Elem()
+	}
+
 	// deconstruct the first struct to validate requested columns
 	// coordinate columns
-	columnMap = make(map[string][]int, numColumns) // column name -> recursive field indices
+	columnMap = make(map[string]*columnField, numColumns) // column name -> field
 	for i := range columns {
+		rt, qualCol, exploded := t, columns[i], false
+		if elemType != nil {
+			if rel, found, err := explodedPath(columns[i], t, explode, o); err != nil {
+				return nil, fmt.Errorf("column %q: %w", columns[i], err)
+			} else if found {
+				rt, qualCol, exploded = elemType, rel, true
+			}
+		}
+		if exploded && qualCol == "" { // the element itself
+			columnMap[columns[i]] = &columnField{typ: elemType, exploded: true, plain: plainKind(elemType)}
+			continue
+		}
 		// map column names to their field indices
 		// if a name is not found, nil it so it can be skipped later
-		_, fo, index, err := FindQualifiedField[any](columns[i], st)
+		field, steps, fo, err := resolvePath(qualCol, rt, o)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("column %q: %w", columns[i], err)
 		}
 		if !fo {
+			if o.strict {
+				unknown = append(unknown, columns[i])
+			}
 			columnMap[columns[i]] = nil
 			continue
 		}
-		columnMap[columns[i]] = index
+		_, omitEmpty, _ := fieldName(field, o)
+		columnMap[columns[i]] = &columnField{
+			steps:     steps,
+			typ:       field.Type,
+			omitEmpty: omitEmpty,
+			wildcard:  slices.ContainsFunc(steps, func(s pathStep) bool { return s.kind == stepWildcard }),
+			indexed:   slices.ContainsFunc(steps, func(s pathStep) bool { return s.kind == stepWildcard || s.kind == stepElem }),
+			exploded:  exploded,
+			plain:     plainKind(field.Type),
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, newUnknownColumnError(t, unknown, o)
+	}
+	return columnMap, nil
+}
+
+// resolveExplode resolves the slice to explode (o.explode) against the struct
+// type t.
+// Returns nil if not exploding or, if o.heterogeneous, t lacks the slice.
+//
+// Returns ErrUnknownColumn if the slice could not be found or ErrNotASlice if
+// it is not a slice or array (or is beneath a wildcard).
+//
+// ! t must be a struct type
+func resolveExplode(t reflect.Type, o *options) (*columnField, error) {
+	if o.explode == "" {
+		return nil, nil
+	}
+	field, steps, found, err := resolvePath(o.explode, t, o)
+	if err != nil {
+		return nil, fmt.Errorf("explode %q: %w", o.explode, err)
+	}
+	if !found {
+		if o.heterogeneous {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("explode %q: %w", o.explode, ErrUnknownColumn)
+	}
+	kind := derefType(field.Type).Kind()
+	if (kind != reflect.Slice && kind != reflect.Array) ||
+		slices.ContainsFunc(steps, func(s pathStep) bool { return s.kind == stepWildcard }) {
+		return nil, fmt.Errorf("explode %q: %w", o.explode, ErrNotASlice)
 	}
-	return
+	return &columnField{steps: steps, typ: field.Type}, nil
 }
+
+// explodedPath returns the remainder of the given column relative to the
+// exploded slice (the empty string if the column is the slice itself), if the
+// column is beneath it.
+// A qualification resolving to the same field as the slice is beneath it, so
+// the column may differ from the explode path in case (given CaseInsensitive)
+// or by promotion.
+//
+// ! t must be a struct type
+func explodedPath(qualCol string, t reflect.Type, explode *columnField, o *options) (rel string, found bool, err error) {
+	for i := 0; i <= len(qualCol); i++ {
+		if i < len(qualCol) && qualCol[i] != '.' {
+			continue
+		}
+		_, steps, fo, err := resolvePath(qualCol[:i], t, o)
+		if err != nil {
+			return "", false, err
+		}
+		if !fo {
+			return "", false, nil // neither can any longer qualification
+		}
+		if stepsEqual(steps, explode.steps) {
+			return strings.TrimPrefix(qualCol[i:], "."), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// stepsEqual returns whether the given paths address the same value.
+func stepsEqual(a, b []pathStep) bool {
+	return slices.EqualFunc(a, b, func(x, y pathStep) bool {
+		return x.kind == y.kind && slices.Equal(x.index, y.index) && x.elem == y.elem &&
+			x.key.IsValid() == y.key.IsValid() && (!x.key.IsValid() || x.key.String() == y.key.String())
+	})
+}
+
+// Returns the qualified names of every field in st (as given by StructFields),
+// minus the blacklisted fields and their descendants.
+// Blacklisted names are resolved as columns, so they may be promoted or (given
+// CaseInsensitive) differ in case.
+//
+// Returns an *UnknownColumnError if o.strict and any blacklisted column could
+// not be found.
+//
+// ! t must be a struct type
+func excludeColumns(t reflect.Type, blacklist []string, exportedOnly bool, o *options) ([]string, error) {
+	all := structFields(t, exportedOnly, o)
+	if len(blacklist) == 0 {
+		return all, nil
+	}
+	// resolve against the record, not elements of the exploded slice, so
+	// every index is comparable
+	whole := *o
+	whole.explode = ""
+	o = &whole
+	blacklistMap, err := buildColumnMap(t, blacklist, o)
+	if err != nil {
+		return nil, err
+	}
+	// resolve every candidate so blacklisted paths can be compared by index
+	// rather than by name
+	lenient := *o
+	lenient.strict = false
+	allMap, err := buildColumnMap(t, all, &lenient)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(all))
+	for _, col := range all {
+		excluded := false
+		index, _ := allMap[col].fieldIndex()
+		for _, b := range blacklistMap {
+			if b == nil {
+				continue
+			}
+			// a blacklisted path excludes itself and its descendants
+			// (map keys cannot be excluded, as StructFields does not descend
+			// into maps)
+			bIndex, ok := b.fieldIndex()
+			if ok && len(index) >= len(bIndex) && slices.Equal(index[:len(bIndex)], bIndex) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			columns = append(columns, col)
+		}
+	}
+	return columns, nil
+}
+
+//#region strict
+
+// UnknownColumnError is returned in strict mode when one or more requested
+// columns do not resolve to a field.
+// It unwraps to ErrUnknownColumn.
+type UnknownColumnError struct {
+	// every unresolved qualified name, in the order requested
+	Columns []string
+	// unresolved qualified name -> the closest valid qualified name, if any
+	// valid name is reasonably close
+	Suggestions map[string]string
+}
+
+func (e *UnknownColumnError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(ErrUnknownColumn.Error())
+	sb.WriteString(": ")
+	for i, col := range e.Columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(strconv.Quote(col))
+		if sug, ok := e.Suggestions[col]; ok {
+			sb.WriteString(" (did you mean " + strconv.Quote(sug) + "?)")
+		}
+	}
+	return sb.String()
+}
+
+func (e *UnknownColumnError) Unwrap() error {
+	return ErrUnknownColumn
+}
+
+// newUnknownColumnError returns an UnknownColumnError for the given columns,
+// suggesting the closest exported fields of st by edit distance.
+func newUnknownColumnError(t reflect.Type, unknown []string, o *options) *UnknownColumnError {
+	e := &UnknownColumnError{Columns: unknown, Suggestions: make(map[string]string)}
+	candidates := structFields(t, true, o) // unexported fields cannot be output
+	for _, col := range unknown {
+		if sug, ok := closestName(col, candidates); ok {
+			e.Suggestions[col] = sug
+		}
+	}
+	return e
+}
+
+// closestName returns the candidate with the smallest edit distance to name,
+// ignoring case (so "id" suggests "ID").
+// Candidates further than half the length of name are not considered close.
+func closestName(name string, candidates []string) (closest string, found bool) {
+	best := len([]rune(name))/2 + 1 // exclusive upper bound
+	folded := strings.ToLower(name)
+	for _, c := range candidates {
+		if d := levenshtein(folded, strings.ToLower(c)); d < best {
+			best, closest, found = d, c, true
+		}
+	}
+	return closest, found
+}
+
+// levenshtein returns the edit distance between a and b, in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// single-row dynamic programming; prev[j] is the distance between the
+	// current prefix of a and rb[:j]
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		diag := prev[0] // distance(ra[:i-1], rb[:j-1])
+		prev[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			next := min(prev[j]+1, prev[j-1]+1, diag+cost)
+			diag, prev[j] = prev[j], next
+		}
+	}
+	return prev[len(rb)]
+}
+
+//#endregion strict
@@ -1,12 +1,14 @@
 package weave
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/charmbracelet/lipgloss"
@@ -239,7 +241,11 @@ func TestToCSV(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := ToCSV(tt.args.st, tt.args.columns); got != tt.want {
+			got, err := ToCSV(tt.args.st, tt.args.columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
 				t.Errorf("\n---ToCSVHash()---\n'%v'\n---want---\n'%v'", got, tt.want)
 			}
 		})
@@ -248,7 +254,11 @@ func TestToCSV(t *testing.T) {
 	t.Run("not a struct", func(t *testing.T) {
 		m := map[int]float32{}
 
-		if got := ToCSV([]map[int]float32{m}, []string{"some", "column", "names"}); got != "" {
+		got, err := ToCSV([]map[int]float32{m}, []string{"some", "column", "names"})
+		if !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+		if got != "" {
 			t.Errorf("expected the empty string, got %v", got)
 		}
 	})
@@ -292,7 +302,10 @@ func TestToCSV(t *testing.T) {
 			)
 		}
 
-		actual := ToCSV(data, []string{"n", "in", "iin", "iiin"})
+		actual, err := ToCSV(data, []string{"n", "in", "iin", "iiin"})
+		if err != nil {
+			t.Fatal(err)
+		}
 		expected := strings.TrimSpace(expectedBldr.String()) // chomp newline
 		if actual != expected {
 			// count newlines in parallel
@@ -339,7 +352,10 @@ func TestToCSV(t *testing.T) {
 		want := "a,ptr\n" +
 			"1,5"
 
-		actual := ToCSV([]ptrstruct{st}, []string{"a", "ptr"})
+		actual, err := ToCSV([]ptrstruct{st}, []string{"a", "ptr"})
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		if actual != want {
 			t.Errorf("\n---ToCSVHash()---\n'%v'\n---want---\n'%v'", actual, want)
@@ -365,24 +381,213 @@ func TestToCSV(t *testing.T) {
 		inptrVal := -9
 		ptrStructVal := ptrstruct{a: 0, b: "B"}
 		v := outer{z: 10, inner: inner{inptr: &inptrVal, p: &ptrStructVal}}
-		actual := ToCSV([]outer{v}, []string{"z", "inptr", "p", "a", "b"})
+		actual, err := ToCSV([]outer{v}, []string{"z", "inptr", "p", "a", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
 		expected := "z,inptr,p,a,b\n" +
 			"10,-9,{0 B},,"
 		if actual != expected {
 			t.Errorf("\n---ToCSVHash()---\n'%v'\n---want---\n'%v'", actual, expected)
 		}
 	})
+
+	t.Run("RFC 4180 quoting", func(t *testing.T) {
+		type msg struct {
+			ID   int
+			Text string
+		}
+
+		tests := []struct {
+			name string
+			text string
+			want string
+		}{
+			{"plain", "hello world", "hello world"},
+			{"empty", "", ""},
+			{"embedded comma", "a,b", `"a,b"`},
+			{"embedded quote", `say "hi"`, `"say ""hi"""`},
+			{"only a quote", `"`, `""""`},
+			{"embedded LF", "line1\nline2", "\"line1\nline2\""},
+			{"embedded CRLF", "line1\r\nline2", "\"line1\r\nline2\""},
+			{"leading whitespace", "  padded", `"  padded"`},
+			{"trailing whitespace", "padded\t", "\"padded\t\""},
+			{"all of the above", ` "x",` + "\r\n", "\" \"\"x\"\",\r\n\""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				actual, err := ToCSV([]msg{{ID: 1, Text: tt.text}}, []string{"ID", "Text"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := "ID,Text\n" + "1," + tt.want
+				if actual != expected {
+					t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, expected)
+				}
+
+				// ensure a compliant reader recovers the original data
+				records, err := csv.NewReader(strings.NewReader(actual)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(records) != 2 {
+					t.Fatalf("expected 2 records, got %d: %q", len(records), records)
+				}
+				// encoding/csv normalizes quoted CRLFs to LF
+				if want := strings.ReplaceAll(tt.text, "\r\n", "\n"); records[1][1] != want {
+					t.Errorf("round trip mismatch: got %q, want %q", records[1][1], want)
+				}
+			})
+		}
+	})
+
+	t.Run("RFC 4180 quoting headers", func(t *testing.T) {
+		type msg struct {
+			Text string
+		}
+		actual, err := ToCSV([]msg{{Text: "x"}}, []string{"Text", "missing,col", `"quoted"`})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `Text,"missing,col","""quoted"""` + "\n" + "x,,"
+		if actual != expected {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, expected)
+		}
+	})
+}
+
+func TestToCSVExclude(t *testing.T) {
+	type auth struct {
+		User  string
+		Token string
+	}
+	type Meta struct {
+		internalID int
+		Region     string
+	}
+	type account struct {
+		Meta
+		mu           sync.Mutex
+		ID           int
+		Name         string
+		PasswordHash string
+		Auth         *auth
+	}
+	data := []*account{
+		{Meta: Meta{internalID: 9, Region: "nz"}, ID: 1, Name: "alice", PasswordHash: "x1", Auth: &auth{User: "a", Token: "t1"}},
+		{Meta: Meta{internalID: 8, Region: "au"}, ID: 2, Name: "bob", PasswordHash: "x2", Auth: &auth{User: "b", Token: "t2"}},
+	}
+
+	tests := []struct {
+		name      string
+		blacklist []string
+		opts      []Option
+		want      string
+	}{
+		{"nil blacklist", nil, nil,
+			"Meta.Region,ID,Name,PasswordHash,Auth.User,Auth.Token\n" +
+				"nz,1,alice,x1,a,t1\n" +
+				"au,2,bob,x2,b,t2"},
+		{"leaves", []string{"PasswordHash", "ID"}, nil,
+			"Meta.Region,Name,Auth.User,Auth.Token\n" +
+				"nz,alice,a,t1\n" +
+				"au,bob,b,t2"},
+		{"parent excludes descendants", []string{"Auth", "PasswordHash"}, nil,
+			"Meta.Region,ID,Name\n" +
+				"nz,1,alice\n" +
+				"au,2,bob"},
+		{"nested leaf", []string{"Auth.Token"}, nil,
+			"Meta.Region,ID,Name,PasswordHash,Auth.User\n" +
+				"nz,1,alice,x1,a\n" +
+				"au,2,bob,x2,b"},
+		{"promoted", []string{"Region"}, nil,
+			"ID,Name,PasswordHash,Auth.User,Auth.Token\n" +
+				"1,alice,x1,a,t1\n" +
+				"2,bob,x2,b,t2"},
+		{"embed by name", []string{"Meta", "Auth"}, nil,
+			"ID,Name,PasswordHash\n" +
+				"1,alice,x1\n" +
+				"2,bob,x2"},
+		{"unexported blacklisted", []string{"internalID", "mu"}, nil,
+			"Meta.Region,ID,Name,PasswordHash,Auth.User,Auth.Token\n" +
+				"nz,1,alice,x1,a,t1\n" +
+				"au,2,bob,x2,b,t2"},
+		{"unknown ignored", []string{"Password", "Auth.Token"}, nil,
+			"Meta.Region,ID,Name,PasswordHash,Auth.User\n" +
+				"nz,1,alice,x1,a\n" +
+				"au,2,bob,x2,b"},
+		{"case insensitive", []string{"passwordhash", "auth"}, []Option{CaseInsensitive()},
+			"Meta.Region,ID,Name\n" +
+				"nz,1,alice\n" +
+				"au,2,bob"},
+		{"everything", []string{"Meta", "ID", "Name", "PasswordHash", "Auth"}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToCSVExclude(data, tt.blacklist, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("\n---ToCSVExclude()---\n'%v'\n---want---\n'%v'", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := ToCSVExclude(data, []string{"Password", "Auth.Token"}, Strict())
+		var uce *UnknownColumnError
+		if !errors.As(err, &uce) || !reflect.DeepEqual(uce.Columns, []string{"Password"}) {
+			t.Errorf("expected an *UnknownColumnError for 'Password', got '%v'", err)
+		}
+	})
+
+	t.Run("superfluous", func(t *testing.T) {
+		got, err := ToCSVExclude[account](nil, []string{"ID"})
+		if err != nil || got != "" {
+			t.Errorf("expected the empty string and no error, got '%v' and '%v'", got, err)
+		}
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		_, err := ToCSVExclude([]int{1}, []string{"ID"})
+		if !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
 }
 
 func TestToTable(t *testing.T) {
 	t.Run("superfluous", func(t *testing.T) {
-		actual := ToTable[any](nil, []string{"A", "B", "c"})
+		actual, err := ToTable[any](nil, []string{"A", "B", "c"})
+		if err != nil {
+			t.Error("Expected no error, got: ", err)
+		}
+
+		if actual != "" {
+			t.Errorf("string mismatch.\nactual%s\nexpected the empty string", actual)
+		}
+	})
 
+	t.Run("not a struct", func(t *testing.T) {
+		actual, err := ToTable([]string{"A", "B"}, []string{"A"})
+		if !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
 		if actual != "" {
 			t.Errorf("string mismatch.\nactual%s\nexpected the empty string", actual)
 		}
 	})
 
+	t.Run("mismatched records", func(t *testing.T) {
+		type x struct{ A int }
+		type y struct{ A int }
+		_, err := ToTable([]any{x{A: 1}, y{A: 2}}, []string{"A"})
+		if !errors.Is(err, ErrMismatchedRecord) {
+			t.Errorf("expected '%v', got '%v'", ErrMismatchedRecord, err)
+		}
+	})
+
 	type d1 struct {
 		one string
 		Two string
@@ -408,7 +613,10 @@ func TestToTable(t *testing.T) {
 
 		expected := DefaultTblStyle().Headers(expectedHeader...).Rows(expectedRows...).Render()
 
-		actual := ToTable(actualData, []string{"A", "B", "c"})
+		actual, err := ToTable(actualData, []string{"A", "B", "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
 		if actual != expected {
 			t.Errorf("string mismatch.\nactual%s\nexpected%s", actual, expected)
 		}
@@ -418,7 +626,10 @@ func TestToTable(t *testing.T) {
 			{A: 1, B: 2, c: "c", depth1: d1{one: "one", Two: "Two"}},
 			{A: 1, B: 2, c: "c", depth1: d1{one: "one", Two: "Two"}},
 		}
-		actual := ToTable(actualData, []string{"A", "B", "c", "depth1.one", "depth1.Two"})
+		actual, err := ToTable(actualData, []string{"A", "B", "c", "depth1.one", "depth1.Two"})
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		expectedRows := [][]string{
 			{"1", "2", "c", "one", "Two"},
@@ -436,7 +647,10 @@ func TestToTable(t *testing.T) {
 			{A: 1, B: 2, c: "c", depth1: d1{one: "one", Two: "Two"}},
 			{A: 1, B: 2, c: "c", depth1: d1{one: "one", Two: "Two"}},
 		}
-		actual := ToTable(actualData, []string{"A", "depth1.one", "depth1.Two"})
+		actual, err := ToTable(actualData, []string{"A", "depth1.one", "depth1.Two"})
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		expectedRows := [][]string{
 			{"1", "one", "Two"},
@@ -454,7 +668,10 @@ func TestToTable(t *testing.T) {
 			{A: 1, B: 2, c: "c", depth1: d1{one: "one", Two: "Two"}},
 			{A: 3, B: 4, c: "c2", depth1: d1{one: "one2", Two: "Two2"}},
 		}
-		actual := ToTable(actualData, []string{"A", "depth1.one", "depth1.Two"})
+		actual, err := ToTable(actualData, []string{"A", "depth1.one", "depth1.Two"})
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		expectedRows := [][]string{
 			{"1", "one", "Two"},
@@ -491,7 +708,10 @@ func TestToTable(t *testing.T) {
 			{A: &A, B: 2, c: &c},
 			{A: &A, B: 2, c: &c},
 		}
-		actual := ToTable(actualData, []string{"A", "B", "c"})
+		actual, err := ToTable(actualData, []string{"A", "B", "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		expectedRows := [][]string{
 			{"1", "2", "c"},
@@ -515,7 +735,10 @@ func TestToTable(t *testing.T) {
 			{A: &A, B: 2, c: &c, D: "D", depth1p: &depth1p},
 			{A: &A, B: 2, c: &c, D: "D", depth1p: &depth1p},
 		}
-		actual := ToTable(actualData, []string{"A", "B", "c", "D", "depth1p.Alpha", "depth1p.beta", "depth1p.one"})
+		actual, err := ToTable(actualData, []string{"A", "B", "c", "D", "depth1p.Alpha", "depth1p.beta", "depth1p.one"})
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		expectedRows := [][]string{
 			{"1", "2", "c", "D", "3.14", "6.28", "one"},
@@ -545,9 +768,12 @@ func TestToTable(t *testing.T) {
 			{A: &A, B: 2, c: &c, D: "D", depth1p: &depth1p},
 			{A: &A, B: 2, c: &c, D: "D", depth1p: &depth1p},
 		}
-		actual := ToTable(actualData,
+		actual, err := ToTable(actualData,
 			[]string{"A", "B", "c", "D", "depth1p.Alpha", "depth1p.beta", "depth1p.one"},
-			styleFunc)
+			TableStyle(styleFunc))
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		expectedRows := [][]string{
 			{"1", "2", "c", "D", "3.14", "6.28", "one"},
@@ -570,7 +796,8 @@ func TestToJSON(t *testing.T) {
 		if err != nil {
 			t.Error("Expected no error, got: ", err)
 		}
-		a2, err = ToJSON[any]([]interface{}{}, nil)
+		// an untyped nil cannot infer the column type (see Columns)
+		a2, err = ToJSON[any]([]interface{}{}, []string(nil))
 		if err != nil {
 			t.Error("Expected no error, got: ", err)
 		}
@@ -792,7 +1019,7 @@ func TestToJSON(t *testing.T) {
 			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", "", actual)
 		}
 	})
-	t.Run("depth 0 unexported panic", func(t *testing.T) {
+	t.Run("depth 0 unexported error", func(t *testing.T) {
 		type d0 struct {
 			A int
 			B *uint
@@ -803,11 +1030,23 @@ func TestToJSON(t *testing.T) {
 			{A: A, B: &B, c: "Linux or death"},
 		}
 
-		defer func() {
-			recover()
-		}()
-		ToJSON(data, []string{"A", "B", "c"})
-		t.Error("ToJSON should have panicked due to unexported value")
+		if _, err := ToJSON(data, []string{"A", "B", "c"}); !errors.Is(err, ErrUnexportedField) {
+			t.Errorf("expected '%v' due to unexported value, got '%v'", ErrUnexportedField, err)
+		}
+	})
+	t.Run("not a struct", func(t *testing.T) {
+		if _, err := ToJSON([]int{1, 2}, []string{"A"}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
+	t.Run("nil record", func(t *testing.T) {
+		actual, err := ToJSON([]any{nil}, []string{"A"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != "[null]" {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", "[null]", actual)
+		}
 	})
 	t.Run("depth 1 simple", func(t *testing.T) {
 		type d1 struct {
@@ -1044,7 +1283,7 @@ func TestToJSONExclude(t *testing.T) {
 			"[{\"B\":0,\"C\":\"C string\",\"D\":\"" + D + "\"},{\"B\":1,\"C\":\"C string\",\"D\":\"" + D + "\"}]",
 			false,
 		},
-		/*{"∀c2r blacklist B and C",
+		{"∀c2r blacklist B and C",
 			args{
 				st: []interface{}{
 					d0{a: 10, B: 0, C: "C string", D: &D},
@@ -1054,7 +1293,27 @@ func TestToJSONExclude(t *testing.T) {
 			},
 			"[{\"D\":\"" + D + "\"},{\"D\":\"" + D + "\"}]",
 			false,
-		},*/
+		},
+		{"∀c2r blacklist unknown and unexported",
+			args{
+				st: []interface{}{
+					d0{a: 10, B: 0, C: "C string", D: &D},
+				},
+				blacklist: []string{"a", "Z"},
+			},
+			"[{\"B\":0,\"C\":\"C string\",\"D\":\"" + D + "\"}]",
+			false,
+		},
+		{"∀c2r blacklist everything",
+			args{
+				st: []interface{}{
+					d0{a: 10, B: 0, C: "C string", D: &D},
+				},
+				blacklist: []string{"B", "C", "D"},
+			},
+			"[{}]",
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1070,163 +1329,1606 @@ func TestToJSONExclude(t *testing.T) {
 	}
 }
 
-func TestFindQualifiedField(t *testing.T) {
-	type lvl3 struct {
-		d int
-		e struct {
-			a string
-			b string
-		}
+func TestToJSONExcludeNested(t *testing.T) {
+	type auth struct {
+		User  string
+		Token string
 	}
-	// strutures to test on
-	type lvl2 struct {
-		b  uint
-		c  *string
-		l3 *lvl3
+	type account struct {
+		ID           int
+		PasswordHash string
+		Auth         *auth
+		Tags         []string
+		internal     int
 	}
-	type lvl1 struct {
-		lvl2
-		l2 lvl2
-		a  string
+	data := []account{
+		{ID: 1, PasswordHash: "x1", Auth: &auth{User: "a", Token: "t1"}, Tags: []string{"x"}, internal: 1},
+		{ID: 2, PasswordHash: "x2", Tags: []string{}},
 	}
 
-	// silence "unused" warnings as we only care about types
-	c := "c"
-	var _ lvl1 = lvl1{l2: lvl2{b: 0, c: &c, l3: &lvl3{d: -8,
-		e: struct {
-			a string
-			b string
-		}{a: "", b: ""}}}, lvl2: lvl2{b: 9}, a: "a"}
+	tests := []struct {
+		name      string
+		blacklist []string
+		opts      []Option
+		want      string
+	}{
+		{"nil blacklist", nil, nil,
+			`[{"Auth":{"Token":"t1","User":"a"},"ID":1,"PasswordHash":"x1","Tags":["x"]},` +
+				`{"Auth":null,"ID":2,"PasswordHash":"x2","Tags":[]}]`},
+		{"nested leaf", []string{"Auth.Token", "PasswordHash"}, nil,
+			`[{"Auth":{"User":"a"},"ID":1,"Tags":["x"]},` +
+				`{"Auth":{"User":null},"ID":2,"Tags":[]}]`},
+		{"whole sub-object", []string{"Auth", "PasswordHash"}, nil,
+			`[{"ID":1,"Tags":["x"]},{"ID":2,"Tags":[]}]`},
+		{"case insensitive", []string{"auth", "passwordhash", "tags"}, []Option{CaseInsensitive()},
+			`[{"ID":1},{"ID":2}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToJSONExclude(data, tt.blacklist, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ToJSONExclude() = %v, want %v", got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("invalid JSON: %v", got)
+			}
+		})
+	}
 
-	t.Run("depth 0", func(t *testing.T) {
-		exp, expFound := reflect.TypeOf(lvl1{}).FieldByName("a")
-		got, gotFound, _, err := FindQualifiedField[lvl1]("a", lvl1{})
-		if err != nil {
-			panic(err)
+	t.Run("maps and interfaces", func(t *testing.T) {
+		type point struct {
+			X, Y int
 		}
-		if gotFound != expFound {
-			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+		type rec struct {
+			M    map[string]int
+			Any  any
+			Next *point
 		}
-
-		if !reflect.DeepEqual(got, exp) {
-			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
-			return
+		d := []rec{
+			{M: map[string]int{"k": 1}, Any: point{X: 1, Y: 2}, Next: &point{X: 3}},
+			{Any: "s"},
 		}
-	})
-	t.Run("depth 0 pointer", func(t *testing.T) {
-		exp, expFound := reflect.TypeOf(lvl2{}).FieldByName("c")
-		got, gotFound, _, err := FindQualifiedField[lvl2]("c", lvl2{})
+		got, err := ToJSONExclude(d, []string{"Next.Y"})
 		if err != nil {
-			panic(err)
-		}
-		if gotFound != expFound {
-			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+			t.Fatal(err)
 		}
-
-		if !reflect.DeepEqual(got, exp) {
-			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
-			return
+		want := `[{"Any":{"X":1,"Y":2},"M":{"k":1},"Next":{"X":3}},{"Any":"s","M":null,"Next":{"X":null}}]`
+		if got != want {
+			t.Errorf("ToJSONExclude() = %v, want %v", got, want)
 		}
 	})
 
-	t.Run("promoted", func(t *testing.T) {
-		exp, expFound := reflect.TypeOf(lvl1{}).FieldByName("b")
-		got, gotFound, _, err := FindQualifiedField[lvl1]("b", lvl1{})
-		if err != nil {
-			panic(err)
-		}
-		if gotFound != expFound {
-			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+	t.Run("nested nil pointers", func(t *testing.T) {
+		type key struct {
+			ID string
+		}
+		type creds struct {
+			User string
+			Key  *key
+		}
+		type rec struct {
+			N     int
+			Creds *creds
+		}
+		d := []*rec{
+			{N: 1, Creds: &creds{User: "a", Key: &key{ID: "k"}}},
+			{N: 2, Creds: &creds{User: "b"}},
+			{N: 3},
+			nil,
+		}
+		tests := []struct {
+			blacklist []string
+			want      string
+		}{
+			{nil, `[{"Creds":{"Key":{"ID":"k"},"User":"a"},"N":1},{"Creds":{"Key":null,"User":"b"},"N":2},{"Creds":null,"N":3},null]`},
+			// Creds is partially excluded, but Key is whole
+			{[]string{"Creds.User"}, `[{"Creds":{"Key":{"ID":"k"}},"N":1},{"Creds":{"Key":null},"N":2},{"Creds":{"Key":null},"N":3},null]`},
+			{[]string{"Creds.Key.ID"}, `[{"Creds":{"User":"a"},"N":1},{"Creds":{"User":"b"},"N":2},{"Creds":{"User":null},"N":3},null]`},
+			{[]string{"N", "Creds"}, `[{},{},{},null]`},
+		}
+		for _, tt := range tests {
+			got, err := ToJSONExclude(d, tt.blacklist)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%v: ToJSONExclude() = %v, want %v", tt.blacklist, got, tt.want)
+			}
 		}
+	})
 
-		if !reflect.DeepEqual(got, exp) {
-			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
-			return
+	t.Run("strict", func(t *testing.T) {
+		_, err := ToJSONExclude(data, []string{"Auth.Tokn"}, Strict())
+		var uce *UnknownColumnError
+		if !errors.As(err, &uce) || uce.Suggestions["Auth.Tokn"] != "Auth.Token" {
+			t.Errorf("expected an *UnknownColumnError suggesting 'Auth.Token', got '%v'", err)
 		}
 	})
+}
 
-	t.Run("promoted pointer", func(t *testing.T) {
-		exp, expFound := reflect.TypeOf(lvl1{}).FieldByName("c")
-		got, gotFound, _, err := FindQualifiedField[lvl1]("c", lvl1{})
-		if err != nil {
-			panic(err)
-		}
-		if gotFound != expFound {
-			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+func TestTags(t *testing.T) {
+	type geo struct {
+		Country string `json:"country"`
+		City    string `json:"city,omitempty"`
+	}
+	type common struct {
+		Seq int `json:"seq"`
+	}
+	type flow struct {
+		common
+		SrcIP    string  `json:"src_ip" csv:"source"`
+		DstIP    string  `weave:"dest" json:"dst_ip"`
+		Port     int     `json:",omitempty"`
+		Secret   string  `weave:"-" json:"secret"`
+		Internal string  `json:"-"`
+		Geo      *geo    `json:"geo"`
+		Hidden   geo     `weave:"-"`
+		Plain    float64 // untagged
+	}
+	data := []flow{
+		{common: common{Seq: 1}, SrcIP: "10.0.0.1", DstIP: "10.0.0.2", Port: 443, Secret: "s", Internal: "i",
+			Geo: &geo{Country: "NZ", City: "Wellington"}, Plain: 1.5},
+		{common: common{Seq: 2}, SrcIP: "10.0.0.3", DstIP: "10.0.0.4", Geo: &geo{Country: "AU"}},
+	}
+
+	t.Run("StructFields", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []Option
+			want []string
+		}{
+			// exportedOnly skips the unexported embed
+			{"no tags", nil, []string{"SrcIP", "DstIP", "Port", "Secret", "Internal",
+				"Geo.Country", "Geo.City", "Hidden.Country", "Hidden.City", "Plain"}},
+			{"default keys", []Option{Tags()}, []string{"src_ip", "dest", "Port",
+				"geo.country", "geo.city", "Plain"}},
+			{"json only", []Option{Tags("json")}, []string{"src_ip", "dst_ip", "Port", "secret",
+				"geo.country", "geo.city", "Hidden.country", "Hidden.city", "Plain"}},
+			{"csv then json", []Option{Tags("csv", "json")}, []string{"source", "dst_ip", "Port", "secret",
+				"geo.country", "geo.city", "Hidden.country", "Hidden.city", "Plain"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := StructFields(flow{}, true, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("StructFields() = %v, want %v", got, tt.want)
+				}
+			})
 		}
+	})
 
-		if !reflect.DeepEqual(got, exp) {
-			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+	t.Run("FindQualifiedField", func(t *testing.T) {
+		tests := []struct {
+			qualCol   string
+			opts      []Option
+			wantFound bool
+			wantIndex []int
+		}{
+			{"src_ip", []Option{Tags()}, true, []int{1}},
+			{"SrcIP", []Option{Tags()}, false, nil},
+			{"SrcIP", nil, true, []int{1}},
+			{"dest", []Option{Tags()}, true, []int{2}},
+			{"dst_ip", []Option{Tags()}, false, nil}, // weave tag takes precedence
+			{"dst_ip", []Option{Tags("json")}, true, []int{2}},
+			{"Port", []Option{Tags()}, true, []int{3}},
+			{"secret", []Option{Tags()}, false, nil},
+			{"Secret", []Option{Tags()}, false, nil},
+			{"Internal", []Option{Tags()}, false, nil},
+			{"geo.country", []Option{Tags()}, true, []int{6, 0}},
+			{"Hidden.country", []Option{Tags()}, false, nil},
+			{"seq", []Option{Tags()}, true, []int{0, 0}}, // promoted
+			{"common.seq", []Option{Tags()}, true, []int{0, 0}},
+			{"GEO.Country", []Option{Tags(), CaseInsensitive()}, true, []int{6, 0}},
+			{"SRC_IP", []Option{Tags(), CaseInsensitive()}, true, []int{1}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.qualCol, func(t *testing.T) {
+				_, found, index, err := FindQualifiedField[flow](tt.qualCol, flow{}, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found != tt.wantFound {
+					t.Fatalf("found mismatch: got(%v) != expected(%v)", found, tt.wantFound)
+				}
+				if found && !reflect.DeepEqual(index, tt.wantIndex) {
+					t.Errorf("path mismatch: got %v, want %v", index, tt.wantIndex)
+				}
+			})
 		}
 	})
-	t.Run("named struct navigation", func(t *testing.T) {
 
-		var expIndexPath []int = []int{1, 0}
-		var exp reflect.StructField = reflect.TypeOf(lvl1{}).FieldByIndex(expIndexPath)
+	columns := []string{"seq", "src_ip", "dest", "Port", "geo.country", "geo.city"}
 
-		got, _, gotIndexPath, err := FindQualifiedField[lvl1]("l2.b", lvl1{})
+	t.Run("ToCSV", func(t *testing.T) {
+		actual, err := ToCSV(data, columns, Tags())
 		if err != nil {
-			panic(err)
+			t.Fatal(err)
 		}
-
-		if !structFieldsEqual(got, exp) {
-			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+		// omitempty does not affect tabular output
+		want := "seq,src_ip,dest,Port,geo.country,geo.city\n" +
+			"1,10.0.0.1,10.0.0.2,443,NZ,Wellington\n" +
+			"2,10.0.0.3,10.0.0.4,0,AU,"
+		if actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
 		}
+	})
 
-		if len(gotIndexPath) != len(expIndexPath) {
-			t.Errorf("path len mismatch: gotPath(%v) != expectedPath(%v)", gotIndexPath, expIndexPath)
+	t.Run("ToTable", func(t *testing.T) {
+		actual, err := ToTable(data, columns, Tags())
+		if err != nil {
+			t.Fatal(err)
 		}
-
-		for i := 0; i < len(gotIndexPath); i++ {
-			if gotIndexPath[i] != expIndexPath[i] {
-				t.Errorf("path mismatch @ index [%d]: gotPath(%v) != expectedPath(%v)", i, gotIndexPath, expIndexPath)
-			}
+		expected := DefaultTblStyle().Headers(columns...).Rows(
+			[]string{"1", "10.0.0.1", "10.0.0.2", "443", "NZ", "Wellington"},
+			[]string{"2", "10.0.0.3", "10.0.0.4", "0", "AU", ""},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
 		}
-
 	})
-	t.Run("named struct navigation outer -> (embed) -> too -> mu", func(t *testing.T) {
-		var expIndexPath []int = []int{0, 1, 0}
-		var exp reflect.StructField = reflect.TypeOf(outer{}).FieldByIndex(expIndexPath)
 
-		got, _, gotIndexPath, err := FindQualifiedField[outer]("too.mu", outer{})
+	t.Run("ToJSON omitempty", func(t *testing.T) {
+		actual, err := ToJSON(data, columns, Tags())
 		if err != nil {
-			panic(err)
+			t.Fatal(err)
 		}
-
-		if !structFieldsEqual(got, exp) {
-			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+		want := `[{"Port":443,"dest":"10.0.0.2","geo":{"city":"Wellington","country":"NZ"},"seq":1,"src_ip":"10.0.0.1"},` +
+			`{"dest":"10.0.0.4","geo":{"country":"AU"},"seq":2,"src_ip":"10.0.0.3"}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
 		}
+	})
 
-		if len(gotIndexPath) != len(expIndexPath) {
-			t.Errorf("path len mismatch: gotPath(%v) != expectedPath(%v)", gotIndexPath, expIndexPath)
+	t.Run("ToCSVExclude", func(t *testing.T) {
+		actual, err := ToCSVExclude(data, []string{"geo", "common", "Plain"}, Tags())
+		if err != nil {
+			t.Fatal(err)
 		}
-
-		for i := 0; i < len(gotIndexPath); i++ {
-			if gotIndexPath[i] != expIndexPath[i] {
-				t.Errorf("path mismatch @ index [%d]: gotPath(%v) != expectedPath(%v)", i, gotIndexPath, expIndexPath)
-			}
+		want := "src_ip,dest,Port\n" +
+			"10.0.0.1,10.0.0.2,443\n" +
+			"10.0.0.3,10.0.0.4,0"
+		if actual != want {
+			t.Errorf("\n---ToCSVExclude()---\n'%v'\n---want---\n'%v'", actual, want)
 		}
-
 	})
-	t.Run("named struct navigation outer -> (embed) -> too -> mu fail (no equity)", func(t *testing.T) {
-
-		// access one field too far within too
-
-		var exp reflect.StructField = reflect.TypeOf(outer{}).FieldByIndex([]int{0, 1, 1})
 
-		got, _, _, err := FindQualifiedField[lvl1]("too.mu", outer{})
+	t.Run("ToJSONExclude", func(t *testing.T) {
+		actual, err := ToJSONExclude(data[1:], []string{"src_ip", "dest", "common"}, Tags())
 		if err != nil {
-			panic(err)
+			t.Fatal(err)
 		}
-
-		if structFieldsEqual(got, exp) {
-			t.Errorf("equality mismatch expected but found equity: got(%v) != expected(%v)", got, exp)
-			return
+		want := `[{"Plain":0,"geo":{"country":"AU"}}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
 		}
 	})
-	t.Run("named struct navigation ptr", func(t *testing.T) {
 
-		var exp reflect.StructField = reflect.TypeOf(lvl1{}).FieldByIndex([]int{0, 1})
+	t.Run("strict suggestions use tag names", func(t *testing.T) {
+		_, err := ToCSV(data, []string{"src_iq"}, Tags(), Strict())
+		var uce *UnknownColumnError
+		if !errors.As(err, &uce) || uce.Suggestions["src_iq"] != "src_ip" {
+			t.Errorf("expected an *UnknownColumnError suggesting 'src_ip', got '%v'", err)
+		}
+	})
+}
+
+func TestColumnAlias(t *testing.T) {
+	type geo struct {
+		Country string
+		City    string
+	}
+	type source struct {
+		IP  string
+		Geo geo
+	}
+	type event struct {
+		Source source
+		Count  int
+	}
+	data := []event{
+		{Source: source{IP: "10.0.0.1", Geo: geo{Country: "NZ", City: "Wellington"}}, Count: 3},
+		{Source: source{IP: "10.0.0.2", Geo: geo{Country: "AU", City: "Perth"}}, Count: 5},
+	}
+	columns := []Column{
+		{Path: "Source.Geo.Country", Alias: "Country"},
+		{Path: "Source.IP"},
+		{Path: "Count", Alias: "Number of events"},
+		{Path: "Source.Geo.City", Alias: "where.city"},
+	}
+
+	t.Run("ToCSV", func(t *testing.T) {
+		actual, err := ToCSV(data, columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "Country,Source.IP,Number of events,where.city\n" +
+			"NZ,10.0.0.1,3,Wellington\n" +
+			"AU,10.0.0.2,5,Perth"
+		if actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("ToCSV alias quoting", func(t *testing.T) {
+		actual, err := ToCSV(data[:1], []Column{{Path: "Count", Alias: "count, total"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "\"count, total\"\n3"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("ToTable", func(t *testing.T) {
+		actual, err := ToTable(data, columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().
+			Headers("Country", "Source.IP", "Number of events", "where.city").
+			Rows([]string{"NZ", "10.0.0.1", "3", "Wellington"}, []string{"AU", "10.0.0.2", "5", "Perth"}).
+			Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		actual, err := ToJSON(data, columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// an unqualified alias flattens; a qualified alias nests by the alias
+		want := `[{"Country":"NZ","Number of events":3,"Source":{"IP":"10.0.0.1"},"where":{"city":"Wellington"}},` +
+			`{"Country":"AU","Number of events":5,"Source":{"IP":"10.0.0.2"},"where":{"city":"Perth"}}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("plain columns are equivalent", func(t *testing.T) {
+		paths := []string{"Source.IP", "Count"}
+		specs := []Column{{Path: "Source.IP"}, {Path: "Count"}}
+		for _, f := range []func(columns any) (string, error){
+			func(c any) (string, error) {
+				if p, ok := c.([]string); ok {
+					return ToCSV(data, p)
+				}
+				return ToCSV(data, c.([]Column))
+			},
+			func(c any) (string, error) {
+				if p, ok := c.([]string); ok {
+					return ToJSON(data, p)
+				}
+				return ToJSON(data, c.([]Column))
+			},
+		} {
+			a, err := f(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := f(specs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a != b {
+				t.Errorf("[]string output '%v' <> []Column output '%v'", a, b)
+			}
+		}
+	})
+
+	t.Run("strict reports paths", func(t *testing.T) {
+		_, err := ToCSV(data, []Column{{Path: "Source.Geo.Contry", Alias: "Country"}}, Strict())
+		var uce *UnknownColumnError
+		if !errors.As(err, &uce) || !reflect.DeepEqual(uce.Columns, []string{"Source.Geo.Contry"}) {
+			t.Errorf("expected an *UnknownColumnError for 'Source.Geo.Contry', got '%v'", err)
+		}
+	})
+}
+
+func TestNilSafety(t *testing.T) {
+	type Embed struct {
+		E string
+	}
+	type nest struct {
+		N *int
+	}
+	type rec struct {
+		*Embed
+		S     *string
+		Nest  *nest
+		Iface any
+		PP    **int
+	}
+	str, n := "s", 5
+	np := &n
+	data := []rec{
+		{}, // everything nil
+		{Embed: &Embed{E: "e"}, S: &str, Nest: &nest{N: &n}, Iface: 1.5, PP: &np},
+		{Nest: &nest{}, Iface: (*int)(nil)}, // nil leaf beneath a set pointer; typed nil in an interface
+	}
+	columns := []string{"E", "S", "Nest.N", "Iface", "PP"}
+
+	t.Run("ToCSV", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []Option
+			want string
+		}{
+			{"empty cell by default", nil,
+				"E,S,Nest.N,Iface,PP\n" +
+					",,,,\n" +
+					"e,s,5,1.5,5\n" +
+					",,,,"},
+			{"NULL", []Option{NullAs("NULL")},
+				"E,S,Nest.N,Iface,PP\n" +
+					"NULL,NULL,NULL,NULL,NULL\n" +
+					"e,s,5,1.5,5\n" +
+					"NULL,NULL,NULL,NULL,NULL"},
+			{"quoted representation", []Option{NullAs("<nil, really>")},
+				"E,S,Nest.N,Iface,PP\n" +
+					`"<nil, really>","<nil, really>","<nil, really>","<nil, really>","<nil, really>"` + "\n" +
+					"e,s,5,1.5,5\n" +
+					`"<nil, really>","<nil, really>","<nil, really>","<nil, really>","<nil, really>"`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				actual, err := ToCSV(data, columns, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual != tt.want {
+					t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("ToTable", func(t *testing.T) {
+		actual, err := ToTable(data, columns, NullAs("NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().Headers(columns...).Rows(
+			[]string{"NULL", "NULL", "NULL", "NULL", "NULL"},
+			[]string{"e", "s", "5", "1.5", "5"},
+			[]string{"NULL", "NULL", "NULL", "NULL", "NULL"},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		// NullAs does not apply to JSON
+		actual, err := ToJSON(data, columns, NullAs("NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"E":null,"Iface":null,"Nest":{"N":null},"PP":null,"S":null},` +
+			`{"E":"e","Iface":1.5,"Nest":{"N":5},"PP":5,"S":"s"},` +
+			`{"E":null,"Iface":null,"Nest":{"N":null},"PP":null,"S":null}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("JSONEncoder", func(t *testing.T) {
+		var sb strings.Builder
+		enc := NewJSONEncoder(&sb, columns, JSONLines)
+		if err := enc.Encode(data[0]); err != nil {
+			t.Fatal(err)
+		}
+		enc.Close()
+		if want := `{"E":null,"Iface":null,"Nest":{"N":null},"PP":null,"S":null}` + "\n"; sb.String() != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, sb.String())
+		}
+	})
+}
+
+func TestPointerRecords(t *testing.T) {
+	type inner struct {
+		C string
+	}
+	type rec struct {
+		A int
+		b string
+		I *inner
+	}
+	data := []*rec{
+		{A: 1, b: "one", I: &inner{C: "c"}},
+		nil,
+		{A: 3, b: "three"},
+	}
+	columns := []string{"A", "b", "I.C"}
+
+	t.Run("ToCSV", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []Option
+			want string
+		}{
+			{"nil represented", []Option{NullAs("NULL")},
+				"A,b,I.C\n" +
+					"1,one,c\n" +
+					"NULL,NULL,NULL\n" +
+					"3,three,NULL"},
+			{"nil skipped", []Option{SkipNilRecords()},
+				"A,b,I.C\n" +
+					"1,one,c\n" +
+					"3,three,"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				actual, err := ToCSV(data, columns, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual != tt.want {
+					t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("ToTable", func(t *testing.T) {
+		actual, err := ToTable(data, columns, SkipNilRecords())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().Headers(columns...).Rows(
+			[]string{"1", "one", "c"},
+			[]string{"3", "three", ""},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		actual, err := ToJSON(data, []string{"A", "I.C"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"A":1,"I":{"C":"c"}},null,{"A":3,"I":{"C":null}}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("Exclude with a leading nil", func(t *testing.T) {
+		actual, err := ToCSVExclude([]*rec{nil, data[0]}, []string{"I"}, SkipNilRecords())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "A\n1"; actual != want {
+			t.Errorf("\n---ToCSVExclude()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+
+		jActual, err := ToJSONExclude([]*rec{nil, data[0]}, []string{"I"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `[null,{"A":1}]`; jActual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, jActual)
+		}
+	})
+
+	t.Run("only nil records", func(t *testing.T) {
+		// typed nils still determine the columns
+		actual, err := ToJSONExclude([]*rec{nil}, nil)
+		if err != nil || actual != "[null]" {
+			t.Errorf("expected '[null]', got '%v' (error: %v)", actual, err)
+		}
+		if _, err := ToJSONExclude([]any{nil}, nil); !errors.Is(err, ErrStructIsNil) {
+			t.Errorf("expected '%v', got '%v'", ErrStructIsNil, err)
+		}
+	})
+
+	t.Run("mixed values and pointers", func(t *testing.T) {
+		actual, err := ToCSV([]any{*data[0], data[2]}, []string{"A"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "A\n1\n3"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("pointer to a non-struct", func(t *testing.T) {
+		n := 1
+		if _, err := ToCSV([]*int{&n}, []string{"A"}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
+}
+
+func TestHeterogeneous(t *testing.T) {
+	type login struct {
+		Time int
+		User string
+	}
+	type packet struct {
+		Time int
+		Src  struct {
+			IP string
+		}
+	}
+	pkt := packet{Time: 2}
+	pkt.Src.IP = "10.0.0.1"
+	data := []any{
+		login{Time: 1, User: "root"},
+		&pkt,
+		login{Time: 3, User: "guest"},
+	}
+	columns := []string{"Time", "User", "Src.IP"}
+
+	t.Run("ToCSV", func(t *testing.T) {
+		actual, err := ToCSV(data, columns, Heterogeneous(), NullAs("NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// columns a type lacks are empty, not NULL
+		want := "Time,User,Src.IP\n" +
+			"1,root,\n" +
+			"2,,10.0.0.1\n" +
+			"3,guest,"
+		if actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("ToTable", func(t *testing.T) {
+		actual, err := ToTable(data, columns, Heterogeneous())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().Headers(columns...).Rows(
+			[]string{"1", "root", ""},
+			[]string{"2", "", "10.0.0.1"},
+			[]string{"3", "guest", ""},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		actual, err := ToJSON(data, columns, Heterogeneous())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"Src":{"IP":null},"Time":1,"User":"root"},` +
+			`{"Src":{"IP":"10.0.0.1"},"Time":2,"User":null},` +
+			`{"Src":{"IP":null},"Time":3,"User":"guest"}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("strict does not apply", func(t *testing.T) {
+		if _, err := ToCSV(data, columns, Heterogeneous(), Strict()); err != nil {
+			t.Errorf("expected no error, got '%v'", err)
+		}
+	})
+
+	t.Run("still requires structs", func(t *testing.T) {
+		if _, err := ToCSV([]any{login{}, 5}, columns, Heterogeneous()); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
+
+	t.Run("mismatched without the option", func(t *testing.T) {
+		if _, err := ToCSV(data, columns); !errors.Is(err, ErrMismatchedRecord) {
+			t.Errorf("expected '%v', got '%v'", ErrMismatchedRecord, err)
+		}
+	})
+
+	t.Run("resolved once per type", func(t *testing.T) {
+		r := resolver{columns: toColumns(columns), opts: newOptions([]Option{Heterogeneous()})}
+		for _, d := range append(data, data...) {
+			if _, err := r.resolve(d); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(r.schemas) != 2 {
+			t.Errorf("expected 2 cached types, got %d", len(r.schemas))
+		}
+		// columns: Time, User, Src.IP
+		if r.recordType != reflect.TypeOf(login{}) || r.fields[2] != nil || r.fields[1] == nil {
+			t.Errorf("expected the columns of the last record's type, got %v", r.fields)
+		}
+	})
+}
+
+func TestMapFields(t *testing.T) {
+	type label string
+	type owner struct {
+		Name string
+	}
+	type rec struct {
+		ID     int
+		Labels map[string]string
+		Owners map[label]*owner
+		Counts map[int]int
+	}
+	data := []rec{
+		{ID: 1, Labels: map[string]string{"env": "prod", "team": "core"}, Owners: map[label]*owner{"primary": {Name: "alice"}}},
+		{ID: 2, Labels: map[string]string{"env": "dev, staging"}},
+		{ID: 3},
+	}
+
+	t.Run("FindQualifiedField", func(t *testing.T) {
+		field, found, index, err := FindQualifiedField[rec]("Owners.primary.Name", rec{})
+		if err != nil || !found {
+			t.Fatalf("expected to find the field, found: %v, error: %v", found, err)
+		}
+		if field.Name != "Name" || index != nil {
+			t.Errorf("expected field 'Name' with no index, got '%v' with %v", field.Name, index)
+		}
+		field, found, _, _ = FindQualifiedField[rec]("Labels.env", rec{})
+		if !found || field.Name != "env" || field.Type.Kind() != reflect.String {
+			t.Errorf("expected a synthesized string field 'env', got %v (found: %v)", field, found)
+		}
+		if _, found, _, _ := FindQualifiedField[rec]("Counts.1", rec{}); found {
+			t.Error("maps without string keys should not be indexed")
+		}
+		if _, found, _, _ := FindQualifiedField[rec]("Labels.env.more", rec{}); found {
+			t.Error("should not qualify beyond a map's leaf values")
+		}
+	})
+
+	t.Run("ToCSV", func(t *testing.T) {
+		actual, err := ToCSV(data, []string{"ID", "Labels.env", "Owners.primary.Name"}, NullAs("NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "ID,Labels.env,Owners.primary.Name\n" +
+			"1,prod,alice\n" +
+			`2,"dev, staging",NULL` + "\n" +
+			"3,NULL,NULL"
+		if actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		actual, err := ToJSON(data[:2], []string{"Labels.env", "Labels.team"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"Labels":{"env":"prod","team":"core"}},{"Labels":{"env":"dev, staging","team":null}}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("MapKeys", func(t *testing.T) {
+		keys, err := MapKeys(data, "Labels")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"Labels.env", "Labels.team"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, keys)
+		}
+		keys, err = MapKeys([]*rec{nil, &data[0]}, "Owners")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"Owners.primary"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, keys)
+		}
+	})
+
+	t.Run("dynamic columns", func(t *testing.T) {
+		keys, err := MapKeys(data, "Labels")
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := ToTable(data, append([]string{"ID"}, keys...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().Headers("ID", "Labels.env", "Labels.team").Rows(
+			[]string{"1", "prod", "core"},
+			[]string{"2", "dev, staging", ""},
+			[]string{"3", "", ""},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("MapKeys errors", func(t *testing.T) {
+		if _, err := MapKeys(data, "ID"); !errors.Is(err, ErrNotAMap) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAMap, err)
+		}
+		if _, err := MapKeys(data, "Counts"); !errors.Is(err, ErrNotAMap) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAMap, err)
+		}
+		if _, err := MapKeys(data, "Lables"); !errors.Is(err, ErrUnknownColumn) {
+			t.Errorf("expected '%v', got '%v'", ErrUnknownColumn, err)
+		}
+	})
+
+	t.Run("keys are resolvable", func(t *testing.T) {
+		d := []rec{{ID: 1, Labels: map[string]string{"": "empty", "k8s.io/app": "web"}}}
+		if _, err := MapKeys(d, "Labels"); !errors.Is(err, ErrUnqualifiableKey) {
+			t.Errorf("expected '%v', got '%v'", ErrUnqualifiableKey, err)
+		}
+
+		// every key returned must resolve back to its value
+		delete(d[0].Labels, "k8s.io/app")
+		keys, err := MapKeys(d, "Labels")
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := ToCSV(d, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Labels.\nempty"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+}
+
+func TestSlicePaths(t *testing.T) {
+	type hop struct {
+		Addr string
+		RTT  *int
+	}
+	type rec struct {
+		ID    int
+		Hops  []hop
+		Ptrs  []*hop
+		Pair  [2]string
+		Names [][]string
+	}
+	rtt := 12
+	data := []rec{
+		{ID: 1, Hops: []hop{{Addr: "10.0.0.1", RTT: &rtt}, {Addr: "10.0.0.2"}}, Ptrs: []*hop{nil, {Addr: "p"}}, Pair: [2]string{"a", "b"},
+			Names: [][]string{{"x", "y"}, {"z"}}},
+		{ID: 2},
+	}
+
+	t.Run("FindQualifiedField", func(t *testing.T) {
+		tests := []struct {
+			qualCol  string
+			found    bool
+			wantName string
+		}{
+			{"Hops.0.Addr", true, "Addr"},
+			{"Hops.*.RTT", true, "RTT"},
+			{"Hops.12", true, "12"},
+			{"Pair.1", true, "1"},
+			{"Pair.2", false, ""}, // beyond array length
+			{"Hops.-1.Addr", false, ""},
+			{"Hops.first.Addr", false, ""},
+			{"Hops.0.Missing", false, ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.qualCol, func(t *testing.T) {
+				field, found, index, err := FindQualifiedField[rec](tt.qualCol, rec{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found != tt.found || field.Name != tt.wantName {
+					t.Errorf("expected (%v, %q), got (%v, %q)", tt.found, tt.wantName, found, field.Name)
+				}
+				if index != nil {
+					t.Errorf("expected no index for a path through a slice, got %v", index)
+				}
+			})
+		}
+	})
+
+	t.Run("ToCSV", func(t *testing.T) {
+		columns := []string{"ID", "Hops.0.Addr", "Hops.1.RTT", "Hops.*.Addr", "Hops.*.RTT", "Ptrs.*.Addr", "Pair.1", "Names.*.*"}
+		tests := []struct {
+			name string
+			opts []Option
+			want string
+		}{
+			{"default joiner", []Option{NullAs("NULL")},
+				"ID,Hops.0.Addr,Hops.1.RTT,Hops.*.Addr,Hops.*.RTT,Ptrs.*.Addr,Pair.1,Names.*.*\n" +
+					`1,10.0.0.1,NULL,"10.0.0.1,10.0.0.2","12,NULL","NULL,p",b,"x,y,z"` + "\n" +
+					"2,NULL,NULL,,,,,"},
+			{"custom joiner", []Option{Joiner(" | ")},
+				"ID,Hops.0.Addr,Hops.1.RTT,Hops.*.Addr,Hops.*.RTT,Ptrs.*.Addr,Pair.1,Names.*.*\n" +
+					`1,10.0.0.1,,10.0.0.1 | 10.0.0.2,"12 | "," | p",b,x | y | z` + "\n" +
+					"2,,,,,,,"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				actual, err := ToCSV(data, columns, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual != tt.want {
+					t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("ToTable", func(t *testing.T) {
+		actual, err := ToTable(data, []Column{{Path: "ID"}, {Path: "Hops.*.Addr", Alias: "Addrs"}}, Joiner("\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().Headers("ID", "Addrs").Rows(
+			[]string{"1", "10.0.0.1\n10.0.0.2"},
+			[]string{"2", ""},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		actual, err := ToJSON(data, []Column{{Path: "ID"}, {Path: "Hops.*.RTT", Alias: "rtts"}, {Path: "Hops.0.Addr", Alias: "first"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"ID":1,"first":"10.0.0.1","rtts":[12,null]},{"ID":2,"first":null,"rtts":[]}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("ToJSON requires aliases", func(t *testing.T) {
+		for _, columns := range [][]string{{"ID", "Hops.*.Addr"}, {"Hops.0.Addr"}} {
+			if _, err := ToJSON(data, columns); !errors.Is(err, ErrUnaliasedColumn) {
+				t.Errorf("%v: expected '%v', got '%v'", columns, ErrUnaliasedColumn, err)
+			}
+		}
+	})
+
+	t.Run("ToJSON collisions", func(t *testing.T) {
+		for _, columns := range [][]Column{
+			{{Path: "Hops"}, {Path: "Hops.0.Addr", Alias: "Hops.first"}},
+			{{Path: "Hops.0.Addr", Alias: "Hops.first"}, {Path: "Hops"}},
+			{{Path: "ID"}, {Path: "Hops.0.Addr", Alias: "ID"}},
+			{{Path: "Hops.0.Addr", Alias: "ID.x"}, {Path: "ID"}},
+		} {
+			if _, err := ToJSON(data, columns); !errors.Is(err, ErrColumnCollision) {
+				t.Errorf("%v: expected '%v', got '%v'", columns, ErrColumnCollision, err)
+			}
+		}
+		// siblings do not collide
+		if _, err := ToJSON(data, []Column{{Path: "Hops.0.Addr", Alias: "h.IDs"}, {Path: "ID", Alias: "h.ID"}}); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestExplode(t *testing.T) {
+	type hop struct {
+		Addr string
+		RTT  int
+	}
+	type rec struct {
+		ID    int
+		Hops  []*hop
+		Names []string
+	}
+	data := []rec{
+		{ID: 1, Hops: []*hop{{Addr: "10.0.0.1", RTT: 3}, {Addr: "10.0.0.2", RTT: 7}}, Names: []string{"a", "b"}},
+		{ID: 2},
+		{ID: 3, Hops: []*hop{nil}, Names: []string{"c"}},
+	}
+	columns := []string{"ID", "Hops.Addr", "Hops.RTT", "Names.0"}
+
+	t.Run("ToCSV", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opts []Option
+			want string
+		}{
+			{"inner", []Option{Explode("Hops")},
+				"ID,Hops.Addr,Hops.RTT,Names.0\n" +
+					"1,10.0.0.1,3,a\n" +
+					"1,10.0.0.2,7,a\n" +
+					"3,,,c"},
+			{"left", []Option{ExplodeLeft("Hops"), NullAs("NULL")},
+				"ID,Hops.Addr,Hops.RTT,Names.0\n" +
+					"1,10.0.0.1,3,a\n" +
+					"1,10.0.0.2,7,a\n" +
+					"2,NULL,NULL,NULL\n" +
+					"3,NULL,NULL,c"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				actual, err := ToCSV(data, columns, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if actual != tt.want {
+					t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("element itself", func(t *testing.T) {
+		actual, err := ToCSV(data, []string{"ID", "Names"}, Explode("Names"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "ID,Names\n1,a\n1,b\n3,c"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("case insensitive", func(t *testing.T) {
+		want := "ID,Hops.Addr\n1,10.0.0.1\n1,10.0.0.2\n3,"
+		for _, tt := range []struct {
+			explode string
+			columns []string
+		}{
+			{"hops", []string{"ID", "Hops.Addr"}},
+			{"Hops", []string{"ID", "hops.addr"}},
+			{"HOPS", []string{"id", "hOps.ADDR"}},
+		} {
+			actual, err := ToCSV(data, tt.columns, Explode(tt.explode), CaseInsensitive())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// header follows the given columns
+			if want := strings.Join(tt.columns, ",") + strings.TrimPrefix(want, "ID,Hops.Addr"); actual != want {
+				t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+			}
+		}
+	})
+
+	t.Run("promoted", func(t *testing.T) {
+		type route struct {
+			Hops []hop
+		}
+		type outer struct {
+			ID int
+			route
+		}
+		d := []outer{{ID: 1, route: route{Hops: []hop{{Addr: "a"}, {Addr: "b"}}}}}
+		actual, err := ToCSV(d, []string{"ID", "route.Hops.Addr"}, Explode("Hops"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "ID,route.Hops.Addr\n1,a\n1,b"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+
+	t.Run("Exclude", func(t *testing.T) {
+		type port struct {
+			Addr string
+			Port int
+		}
+		type route struct {
+			ID   int
+			Name string
+			Hops []port
+		}
+		d := []route{{ID: 1, Name: "r", Hops: []port{{Addr: "a", Port: 1}, {Addr: "b", Port: 2}}}}
+
+		actual, err := ToCSVExclude(d, []string{"Hops.Addr"}, Explode("Hops"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// StructFields does not descend into slices, so the slice is output
+		// as the element itself
+		if want := "ID,Name,Hops\n1,r,{a 1}\n1,r,{b 2}"; actual != want {
+			t.Errorf("\n---ToCSVExclude()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+		if actual, err = ToCSVExclude(d, []string{"Hops.Port", "Hops"}, Explode("Hops")); err != nil {
+			t.Fatal(err)
+		} else if want := "ID,Name\n1,r\n1,r"; actual != want {
+			t.Errorf("\n---ToCSVExclude()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+
+		jActual, err := ToJSONExclude(d, []string{"Hops.Port"}, Explode("Hops"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `[{"Hops":{"Addr":"a","Port":1},"ID":1,"Name":"r"},{"Hops":{"Addr":"b","Port":2},"ID":1,"Name":"r"}]`; jActual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, jActual)
+		}
+	})
+
+	t.Run("ToTable", func(t *testing.T) {
+		actual, err := ToTable(data, columns, ExplodeLeft("Hops"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := DefaultTblStyle().Headers(columns...).Rows(
+			[]string{"1", "10.0.0.1", "3", "a"},
+			[]string{"1", "10.0.0.2", "7", "a"},
+			[]string{"2", "", "", ""},
+			[]string{"3", "", "", "c"},
+		).Render()
+		if actual != expected {
+			t.Errorf("string mismatch.\nactual\n%s\nexpected\n%s", actual, expected)
+		}
+	})
+
+	t.Run("ToJSON", func(t *testing.T) {
+		actual, err := ToJSON(data[:2], []string{"ID", "Hops.Addr"}, Explode("Hops"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `[{"Hops":{"Addr":"10.0.0.1"},"ID":1},{"Hops":{"Addr":"10.0.0.2"},"ID":1}]`
+		if actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := ToCSV(data, columns, Explode("Hopz")); !errors.Is(err, ErrUnknownColumn) {
+			t.Errorf("expected '%v', got '%v'", ErrUnknownColumn, err)
+		}
+		if _, err := ToCSV(data, columns, Explode("ID")); !errors.Is(err, ErrNotASlice) {
+			t.Errorf("expected '%v', got '%v'", ErrNotASlice, err)
+		}
+		if _, err := ToCSV(data, columns, Explode("Hops.*")); !errors.Is(err, ErrNotASlice) {
+			t.Errorf("expected '%v', got '%v'", ErrNotASlice, err)
+		}
+		if _, err := ToCSV(data, []string{"Hops.Adr"}, Explode("Hops"), Strict()); !errors.Is(err, ErrUnknownColumn) {
+			t.Errorf("expected '%v', got '%v'", ErrUnknownColumn, err)
+		}
+	})
+}
+
+func TestCycles(t *testing.T) {
+	type Node struct {
+		Val  int
+		Next *Node
+	}
+	type B struct {
+		X  int
+		BA *struct{ Y int }
+	}
+	type A struct {
+		Name string
+		B    B
+	}
+
+	t.Run("StructFields", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			st            any
+			opts          []Option
+			wantColumns   []string
+			wantTruncated []string
+		}{
+			{"self-referential", Node{}, nil,
+				[]string{"Val", "Next"}, []string{"Next"}},
+			{"self-referential, max depth", Node{}, []Option{MaxDepth(3)},
+				[]string{"Val", "Next.Val", "Next.Next.Val", "Next.Next.Next"}, []string{"Next.Next.Next"}},
+			{"not recursive", A{}, nil,
+				[]string{"Name", "B.X", "B.BA.Y"}, nil},
+			{"not recursive, max depth", A{}, []Option{MaxDepth(2)},
+				[]string{"Name", "B.X", "B.BA"}, []string{"B.BA"}},
+			{"max depth 1", A{}, []Option{MaxDepth(1)},
+				[]string{"Name", "B"}, []string{"B"}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				columns, truncated, err := StructFieldsTruncated(tt.st, false, tt.opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(columns, tt.wantColumns) {
+					t.Errorf("columns: want <> actual:\nwant: '%v'\nactual: '%v'\n", tt.wantColumns, columns)
+				}
+				if !reflect.DeepEqual(truncated, tt.wantTruncated) {
+					t.Errorf("truncated: want <> actual:\nwant: '%v'\nactual: '%v'\n", tt.wantTruncated, truncated)
+				}
+			})
+		}
+	})
+
+	t.Run("mutually recursive", func(t *testing.T) {
+		type Group struct {
+			Name    string
+			Members []string
+			Parent  *Group
+		}
+		type User struct {
+			Name  string
+			Group *Group
+			Boss  *User
+		}
+		columns, truncated, err := StructFieldsTruncated(&User{}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Name", "Group.Name", "Group.Members", "Group.Parent", "Boss"}
+		if !reflect.DeepEqual(columns, want) {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, columns)
+		}
+		if want := []string{"Group.Parent", "Boss"}; !reflect.DeepEqual(truncated, want) {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, truncated)
+		}
+	})
+
+	t.Run("cyclic values", func(t *testing.T) {
+		n := &Node{Val: 1}
+		n.Next = n
+		actual, err := ToCSV([]*Node{n}, []string{"Val", "Next.Next.Next.Val"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Val,Next.Next.Next.Val\n1,1"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+		if _, err := ToCSVExclude([]*Node{n}, nil); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("cyclic pointer chain", func(t *testing.T) {
+		type rec struct {
+			V any
+		}
+		var p any
+		p = &p // an interface holding a pointer to itself
+		actual, err := ToCSV([]rec{{V: p}}, []string{"V"}, NullAs("NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "V\nNULL"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+		if actual, err := ToJSON([]rec{{V: p}}, []string{"V"}); err != nil || actual != `[{"V":null}]` {
+			t.Errorf("expected a null value, got '%v' (error: %v)", actual, err)
+		}
+	})
+
+	t.Run("cyclic value within a slice", func(t *testing.T) {
+		type rec struct {
+			Nodes []*Node
+		}
+		n := &Node{Val: 1}
+		n.Next = n
+		actual, err := ToJSON([]rec{{Nodes: []*Node{n}}}, []string{"Nodes"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `[{"Nodes":[{"Next":null,"Val":1}]}]`; actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("truncated cyclic values", func(t *testing.T) {
+		type node struct {
+			Val  int
+			Next *node
+		}
+		n := &node{Val: 1}
+		n.Next = n
+		chain := &node{Val: 1, Next: &node{Val: 2}}
+		data := []*node{n, chain}
+
+		for range 2 { // output must not vary between runs
+			actual, err := ToCSVExclude(data, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "Val,Next\n1,{1 &{1 <cycle>}}\n1,{2 <nil>}"; actual != want {
+				t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+			}
+			actual, err = ToJSONExclude(data, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := `[{"Next":{"Next":{"Next":null,"Val":1},"Val":1},"Val":1},{"Next":{"Val":2,"Next":null},"Val":1}]`; actual != want {
+				t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+			}
+		}
+	})
+
+	t.Run("cyclic nested values", func(t *testing.T) {
+		type ring struct {
+			Name  string
+			Peers map[string]*ring
+			Links []*ring
+		}
+		r := &ring{Name: "a", Peers: map[string]*ring{}}
+		b := &ring{Name: "b", Links: []*ring{r}}
+		r.Peers["z"], r.Peers["b"] = r, b
+		actual, err := ToCSV([]*ring{r}, []string{"Peers", "Links"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Peers,Links\nmap[b:&{b map[] [&{a <cycle> []}]} z:&{a <cycle> []}],[]"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+	})
+}
+
+func TestStrict(t *testing.T) {
+	type geo struct {
+		Country string
+	}
+	type host struct {
+		IP   string
+		Name string
+		Geo  geo
+	}
+	type event struct {
+		Host    host
+		Message string
+	}
+	data := []event{{Host: host{IP: "10.0.0.1", Name: "alpha", Geo: geo{Country: "NZ"}}, Message: "hi"}}
+
+	t.Run("all columns known", func(t *testing.T) {
+		columns := []string{"Host.IP", "Host.Geo.Country", "Message"}
+		csvOut, err := ToCSV(data, columns, Strict())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "Host.IP,Host.Geo.Country,Message\n10.0.0.1,NZ,hi"; csvOut != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", csvOut, want)
+		}
+		if _, err := ToTable(data, columns, Strict()); err != nil {
+			t.Error(err)
+		}
+		if _, err := ToJSON(data, columns, Strict()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("lenient by default", func(t *testing.T) {
+		if _, err := ToCSV(data, []string{"Host.IP", "Hots.IP"}); err != nil {
+			t.Errorf("expected unknown columns to be ignored by default, got %v", err)
+		}
+	})
+
+	t.Run("unknown columns", func(t *testing.T) {
+		columns := []string{"Hots.IP", "Message", "Host.Geo.Contry", "zzzzzzzzzz"}
+		check := func(t *testing.T, err error) {
+			t.Helper()
+			if !errors.Is(err, ErrUnknownColumn) {
+				t.Fatalf("expected '%v', got '%v'", ErrUnknownColumn, err)
+			}
+			var uce *UnknownColumnError
+			if !errors.As(err, &uce) {
+				t.Fatalf("expected an *UnknownColumnError, got %T", err)
+			}
+			if want := []string{"Hots.IP", "Host.Geo.Contry", "zzzzzzzzzz"}; !reflect.DeepEqual(uce.Columns, want) {
+				t.Errorf("unresolved columns mismatch: got %v, want %v", uce.Columns, want)
+			}
+			wantSug := map[string]string{"Hots.IP": "Host.IP", "Host.Geo.Contry": "Host.Geo.Country"}
+			if !reflect.DeepEqual(uce.Suggestions, wantSug) {
+				t.Errorf("suggestion mismatch: got %v, want %v", uce.Suggestions, wantSug)
+			}
+			for _, s := range []string{`"Hots.IP" (did you mean "Host.IP"?)`, `"zzzzzzzzzz"`} {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("expected error message to contain %s, got '%v'", s, err)
+				}
+			}
+		}
+
+		t.Run("ToCSV", func(t *testing.T) {
+			out, err := ToCSV(data, columns, Strict())
+			check(t, err)
+			if out != "" {
+				t.Errorf("expected no output, got '%v'", out)
+			}
+		})
+		t.Run("ToTable", func(t *testing.T) {
+			_, err := ToTable(data, columns, Strict())
+			check(t, err)
+		})
+		t.Run("ToJSON", func(t *testing.T) {
+			_, err := ToJSON(data, columns, Strict())
+			check(t, err)
+		})
+	})
+
+	t.Run("suggestions", func(t *testing.T) {
+		type rec struct {
+			ID     int
+			secret string
+			Secure bool
+		}
+		_, err := ToCSV([]rec{{}}, []string{"id", "secrt"}, Strict())
+		var uce *UnknownColumnError
+		if !errors.As(err, &uce) {
+			t.Fatalf("expected an *UnknownColumnError, got %T", err)
+		}
+		// case-only typos are close; unexported fields are not suggested
+		if want := map[string]string{"id": "ID", "secrt": "Secure"}; !reflect.DeepEqual(uce.Suggestions, want) {
+			t.Errorf("suggestion mismatch: got %v, want %v", uce.Suggestions, want)
+		}
+	})
+}
+
+func Test_levenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"kitten", "sitting", 3},
+		{"Hots.IP", "Host.IP", 2},
+		{"flaw", "lawn", 2},
+		{"héllo", "hello", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindQualifiedField(t *testing.T) {
+	type lvl3 struct {
+		d int
+		e struct {
+			a string
+			b string
+		}
+	}
+	// strutures to test on
+	type lvl2 struct {
+		b  uint
+		c  *string
+		l3 *lvl3
+	}
+	type lvl1 struct {
+		lvl2
+		l2 lvl2
+		a  string
+	}
+
+	// silence "unused" warnings as we only care about types
+	c := "c"
+	var _ lvl1 = lvl1{l2: lvl2{b: 0, c: &c, l3: &lvl3{d: -8,
+		e: struct {
+			a string
+			b string
+		}{a: "", b: ""}}}, lvl2: lvl2{b: 9}, a: "a"}
+
+	t.Run("depth 0", func(t *testing.T) {
+		exp, expFound := reflect.TypeOf(lvl1{}).FieldByName("a")
+		got, gotFound, _, err := FindQualifiedField[lvl1]("a", lvl1{})
+		if err != nil {
+			panic(err)
+		}
+		if gotFound != expFound {
+			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+		}
+
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+			return
+		}
+	})
+	t.Run("depth 0 pointer", func(t *testing.T) {
+		exp, expFound := reflect.TypeOf(lvl2{}).FieldByName("c")
+		got, gotFound, _, err := FindQualifiedField[lvl2]("c", lvl2{})
+		if err != nil {
+			panic(err)
+		}
+		if gotFound != expFound {
+			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+		}
+
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+			return
+		}
+	})
+
+	t.Run("promoted", func(t *testing.T) {
+		exp, expFound := reflect.TypeOf(lvl1{}).FieldByName("b")
+		got, gotFound, _, err := FindQualifiedField[lvl1]("b", lvl1{})
+		if err != nil {
+			panic(err)
+		}
+		if gotFound != expFound {
+			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+		}
+
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+			return
+		}
+	})
+
+	t.Run("promoted pointer", func(t *testing.T) {
+		exp, expFound := reflect.TypeOf(lvl1{}).FieldByName("c")
+		got, gotFound, _, err := FindQualifiedField[lvl1]("c", lvl1{})
+		if err != nil {
+			panic(err)
+		}
+		if gotFound != expFound {
+			t.Errorf("found mismatch: got(%v) != expected(%v)", gotFound, expFound)
+		}
+
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+		}
+	})
+	t.Run("named struct navigation", func(t *testing.T) {
+
+		var expIndexPath []int = []int{1, 0}
+		var exp reflect.StructField = reflect.TypeOf(lvl1{}).FieldByIndex(expIndexPath)
+
+		got, _, gotIndexPath, err := FindQualifiedField[lvl1]("l2.b", lvl1{})
+		if err != nil {
+			panic(err)
+		}
+
+		if !structFieldsEqual(got, exp) {
+			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+		}
+
+		if len(gotIndexPath) != len(expIndexPath) {
+			t.Errorf("path len mismatch: gotPath(%v) != expectedPath(%v)", gotIndexPath, expIndexPath)
+		}
+
+		for i := 0; i < len(gotIndexPath); i++ {
+			if gotIndexPath[i] != expIndexPath[i] {
+				t.Errorf("path mismatch @ index [%d]: gotPath(%v) != expectedPath(%v)", i, gotIndexPath, expIndexPath)
+			}
+		}
+
+	})
+	t.Run("named struct navigation outer -> (embed) -> too -> mu", func(t *testing.T) {
+		var expIndexPath []int = []int{0, 1, 0}
+		var exp reflect.StructField = reflect.TypeOf(outer{}).FieldByIndex(expIndexPath)
+
+		got, _, gotIndexPath, err := FindQualifiedField[outer]("too.mu", outer{})
+		if err != nil {
+			panic(err)
+		}
+
+		if !structFieldsEqual(got, exp) {
+			t.Errorf("equality mismatch: got(%v) != expected(%v)", got, exp)
+		}
+
+		if len(gotIndexPath) != len(expIndexPath) {
+			t.Errorf("path len mismatch: gotPath(%v) != expectedPath(%v)", gotIndexPath, expIndexPath)
+		}
+
+		for i := 0; i < len(gotIndexPath); i++ {
+			if gotIndexPath[i] != expIndexPath[i] {
+				t.Errorf("path mismatch @ index [%d]: gotPath(%v) != expectedPath(%v)", i, gotIndexPath, expIndexPath)
+			}
+		}
+
+	})
+	t.Run("named struct navigation outer -> (embed) -> too -> mu fail (no equity)", func(t *testing.T) {
+
+		// access one field too far within too
+
+		var exp reflect.StructField = reflect.TypeOf(outer{}).FieldByIndex([]int{0, 1, 1})
+
+		got, _, _, err := FindQualifiedField[lvl1]("too.mu", outer{})
+		if err != nil {
+			panic(err)
+		}
+
+		if structFieldsEqual(got, exp) {
+			t.Errorf("equality mismatch expected but found equity: got(%v) != expected(%v)", got, exp)
+			return
+		}
+	})
+	t.Run("named struct navigation ptr", func(t *testing.T) {
+
+		var exp reflect.StructField = reflect.TypeOf(lvl1{}).FieldByIndex([]int{0, 1})
 
 		got, _, _, err := FindQualifiedField[lvl1]("l2.c", lvl1{})
 		if err != nil {
@@ -1294,6 +2996,16 @@ func TestFindQualifiedField(t *testing.T) {
 		}
 	})
 
+	t.Run("qualified beyond a leaf", func(t *testing.T) {
+		_, found, _, err := FindQualifiedField[lvl1]("a.b", lvl1{})
+		if err != nil {
+			t.Error(err)
+		}
+		if found {
+			t.Error("found field. expected found == false")
+		}
+	})
+
 	t.Run("empty column name", func(t *testing.T) {
 		field, found, index, err := FindQualifiedField[lvl1]("", lvl1{})
 		if err != nil {
@@ -1312,8 +3024,8 @@ func TestFindQualifiedField(t *testing.T) {
 
 	t.Run("nil struct", func(t *testing.T) {
 		field, found, index, err := FindQualifiedField[lvl1]("a", nil)
-		want := errors.New(ErrStructIsNil)
-		if errors.Is(err, want) {
+		want := ErrStructIsNil
+		if !errors.Is(err, want) {
 			t.Errorf("Expected '%v', got '%v'", want, err)
 		}
 		if found != false {
@@ -1329,8 +3041,8 @@ func TestFindQualifiedField(t *testing.T) {
 
 	t.Run("not a struct", func(t *testing.T) {
 		field, found, index, err := FindQualifiedField[map[string]string]("a", map[string]string{})
-		want := errors.New(ErrNotAStruct)
-		if errors.Is(err, want) {
+		want := ErrNotAStruct
+		if !errors.Is(err, want) {
 			t.Errorf("Expected '%v', got '%v'", want, err)
 		}
 		if found != false {
@@ -1345,6 +3057,129 @@ func TestFindQualifiedField(t *testing.T) {
 	})
 }
 
+func TestFindQualifiedFieldCaseInsensitive(t *testing.T) {
+	type Geo struct {
+		Country string
+	}
+	type Embed struct {
+		Region string
+	}
+	type Host struct {
+		Embed
+		IP  string
+		Geo *Geo
+	}
+	type event struct {
+		Host Host
+		ID   int
+	}
+
+	t.Run("case sensitive by default", func(t *testing.T) {
+		_, found, _, err := FindQualifiedField[event]("host.ip", event{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found {
+			t.Error("expected 'host.ip' not to be found without CaseInsensitive")
+		}
+	})
+
+	tests := []struct {
+		name      string
+		qualCol   string
+		wantIndex []int
+	}{
+		{"depth 0", "id", []int{1}},
+		{"depth 1", "host.ip", []int{0, 1}},
+		{"mixed case", "HOST.iP", []int{0, 1}},
+		{"through pointer", "host.geo.COUNTRY", []int{0, 2, 0}},
+		{"promoted", "host.region", []int{0, 0, 0}},
+		{"embed by name", "host.embed.region", []int{0, 0, 0}},
+		{"exact", "Host.IP", []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, found, index, err := FindQualifiedField[event](tt.qualCol, event{}, CaseInsensitive())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !found {
+				t.Fatalf("expected '%v' to be found", tt.qualCol)
+			}
+			if !reflect.DeepEqual(index, tt.wantIndex) {
+				t.Errorf("path mismatch: got %v, want %v", index, tt.wantIndex)
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		_, found, _, err := FindQualifiedField[event]("host.mac", event{}, CaseInsensitive())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found {
+			t.Error("expected 'host.mac' not to be found")
+		}
+	})
+
+	type clash struct {
+		Addr string
+		ADDR string
+		Port int
+	}
+
+	t.Run("exact match preferred over ambiguity", func(t *testing.T) {
+		_, found, index, err := FindQualifiedField[clash]("ADDR", clash{}, CaseInsensitive())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || !reflect.DeepEqual(index, []int{1}) {
+			t.Errorf("expected exact match at [1], got found=%v index=%v", found, index)
+		}
+	})
+
+	t.Run("ambiguous", func(t *testing.T) {
+		_, found, _, err := FindQualifiedField[clash]("addr", clash{}, CaseInsensitive())
+		if !errors.Is(err, ErrAmbiguousColumn) {
+			t.Errorf("expected '%v', got '%v'", ErrAmbiguousColumn, err)
+		}
+		if found {
+			t.Error("ambiguous column should not be found")
+		}
+	})
+
+	type clashEmbedA struct{ Name string }
+	type clashEmbedB struct{ NAME string }
+	type promotedClash struct {
+		clashEmbedA
+		clashEmbedB
+	}
+
+	t.Run("ambiguous promoted", func(t *testing.T) {
+		_, _, _, err := FindQualifiedField[promotedClash]("name", promotedClash{}, CaseInsensitive())
+		if !errors.Is(err, ErrAmbiguousColumn) {
+			t.Errorf("expected '%v', got '%v'", ErrAmbiguousColumn, err)
+		}
+	})
+
+	t.Run("output modules", func(t *testing.T) {
+		data := []event{{Host: Host{IP: "10.0.0.1", Embed: Embed{Region: "ap"}}, ID: 7}}
+		actual, err := ToCSV(data, []string{"host.ip", "host.region", "id"}, CaseInsensitive())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// headers are output as requested
+		if want := "host.ip,host.region,id\n10.0.0.1,ap,7"; actual != want {
+			t.Errorf("\n---ToCSV()---\n'%v'\n---want---\n'%v'", actual, want)
+		}
+
+		_, err = ToCSV([]clash{{}}, []string{"addr"}, CaseInsensitive())
+		if !errors.Is(err, ErrAmbiguousColumn) {
+			t.Errorf("expected '%v', got '%v'", ErrAmbiguousColumn, err)
+		}
+	})
+}
+
 // Fields returned by FindQualifiedField retain their true, nested index while
 // fetching via FindByIndex or iterative Field() calls do not.
 // Therefore, we cannot use DeepEqual() for comparison, but want to compare as
@@ -1363,14 +3198,14 @@ func structFieldsEqual(x reflect.StructField, y reflect.StructField) bool {
 func TestStructFieldsErrors(t *testing.T) {
 	t.Run("struct is nil", func(t *testing.T) {
 		c, err := StructFields(nil, true)
-		if err.Error() != ErrStructIsNil || c != nil {
+		if !errors.Is(err, ErrStructIsNil) || c != nil {
 			t.Errorf("Error value mismatch: err: %v c: %v", err, c)
 		}
 	})
 	t.Run("not a struct", func(t *testing.T) {
 		m := make(map[string]int)
 		c, err := StructFields(m, true)
-		if err.Error() != ErrNotAStruct || c != nil {
+		if !errors.Is(err, ErrNotAStruct) || c != nil {
 			t.Errorf("Error value mismatch: err: %v c: %v", err, c)
 		}
 	})
@@ -0,0 +1,245 @@
+package weave
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Given an array of an arbitrary struct and the list of *fully-qualified* fields,
+// outputs an XML document containing the data in the array of the struct: a
+// root element (see XMLRoot) containing a record element (see XMLRecord) per
+// record.
+// Each column is output as an element nested by its qualification, as ToJSON
+// nests objects; ex: "Src.Geo.Country" is output as
+// <Src><Geo><Country>NZ</Country></Geo></Src>.
+// Columns given to XMLAttrs are instead output as attributes of their parent
+// element (the record element, if unqualified).
+//
+// Values are stringified identically to ToTable and escaped. The values of a
+// wildcard column are output as repeated elements.
+// Unknown columns (and, for Heterogeneous records, columns a type lacks) are
+// omitted, as are empty values of omitempty-tagged fields.
+// Nil records are output as an empty record element, unless SkipNilRecords is
+// given.
+//
+// Columns may be given as qualified names or as Column specs; as every
+// qualification must be a valid XML name, alias index and wildcard columns
+// (ex: "Hops.*.Addr"). Returns ErrInvalidXMLName otherwise.
+// Returns ErrDuplicateXMLName if multiple columns would be output as the same
+// element or attribute, or if a column would be output as an element that also
+// contains the element of another column (ex: "Src" and "Src.IP").
+//
+// ! Returns an empty root element (and no error) if columns or st are empty
+func ToXML[Any any, C Columns](st []Any, columns C, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	for _, name := range []string{o.xmlRoot, o.xmlRecord} {
+		if !isXMLName(name) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidXMLName, name)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	if st == nil || len(st) < 1 || len(columns) < 1 { // superfluous request
+		sb.WriteString("<" + o.xmlRoot + "></" + o.xmlRoot + ">")
+		return sb.String(), nil
+	}
+
+	r := resolver{columns: toColumns(columns), opts: o}
+	paths, attrs, err := xmlPaths(r.columns, o)
+	if err != nil {
+		return "", err
+	}
+
+	sb.WriteString("<" + o.xmlRoot + ">\n")
+	for i := range st {
+		structVals, err := r.resolve(st[i])
+		if err != nil {
+			return "", fmt.Errorf("record %d: %w", i, err)
+		}
+		if !structVals.IsValid() { // nil record
+			if o.skipNil {
+				continue
+			}
+			(&xmlNode{name: o.xmlRecord}).write(&sb)
+			sb.WriteString("\n")
+			continue
+		}
+		for _, elem := range r.elements(structVals) {
+			structToXML(structVals, elem, &r, paths, attrs).write(&sb)
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString("</" + o.xmlRoot + ">")
+
+	return sb.String(), nil
+}
+
+// helper function for ToXML
+// validates and splits the output path of each column, returning the paths and
+// whether each column is output as an attribute (see XMLAttrs).
+//
+// Returns ErrInvalidXMLName if a qualification is not a valid XML name or
+// ErrDuplicateXMLName if multiple columns would be output as the same attribute
+// or the same element (which would either be malformed or silently overwrite a
+// value), or as an element containing another's (which would be mixed content).
+func xmlPaths(columns []Column, o *options) (paths [][]string, attrs []bool, err error) {
+	paths, attrs = make([][]string, len(columns)), make([]bool, len(columns))
+	seen := make(map[string]string, len(columns))    // output path -> column
+	parents := make(map[string]string, len(columns)) // element path -> a column nested within it
+	for i, col := range columns {
+		paths[i] = strings.Split(col.Name(), ".")
+		for _, name := range paths[i] {
+			if !isXMLName(name) {
+				return nil, nil, fmt.Errorf("column %q: %w: %q", col.Name(), ErrInvalidXMLName, name)
+			}
+		}
+		attrs[i] = slices.Contains(o.xmlAttrs, col.Path)
+
+		// key attributes distinctly from elements, as they may share names
+		key := col.Name()
+		if attrs[i] {
+			parent := paths[i][:len(paths[i])-1]
+			key = strings.Join(parent, ".") + "@" + paths[i][len(paths[i])-1]
+		}
+		if prior, found := seen[key]; found {
+			return nil, nil, fmt.Errorf("column %q: %w: %q is also output by column %q", col.Path, ErrDuplicateXMLName, col.Name(), prior)
+		}
+		seen[key] = col.Path
+		if attrs[i] { // attributes never contain text, so may be nested within any element
+			continue
+		}
+
+		// an element cannot hold both text and the elements of other columns
+		if prior, found := parents[col.Name()]; found {
+			return nil, nil, fmt.Errorf("column %q: %w: %q is also the parent of column %q", col.Path, ErrDuplicateXMLName, col.Name(), prior)
+		}
+		for j := 1; j < len(paths[i]); j++ {
+			parent := strings.Join(paths[i][:j], ".")
+			if prior, found := seen[parent]; found {
+				return nil, nil, fmt.Errorf("column %q: %w: %q is also output by column %q", col.Path, ErrDuplicateXMLName, parent, prior)
+			}
+			parents[parent] = col.Path
+		}
+	}
+	return paths, attrs, nil
+}
+
+// helper function for ToXML
+// returns the record element populated by the data in the struct (and exploded
+// element) that corresponds to the columns, nested by qualification.
+func structToXML(structVals, elem reflect.Value, r *resolver, paths [][]string, attrs []bool) *xmlNode {
+	record := &xmlNode{name: r.opts.xmlRecord}
+	for i := range r.columns {
+		cf := r.fields[i]
+		if cf == nil { // unknown (or lacked) column
+			continue
+		}
+		src := source(structVals, elem, cf)
+		path := paths[i]
+		parent := record.descend(path[:len(path)-1])
+		name := path[len(path)-1]
+
+		if attrs[i] {
+			if cf.omitEmpty && isEmptyField(src, cf) {
+				continue
+			}
+			parent.attrs = append(parent.attrs, xmlAttr{name: name, value: stringifyField(src, cf, r.opts)})
+			continue
+		}
+		if cf.wildcard { // repeated elements
+			for _, data := range fieldValues(src, cf) {
+				parent.children = append(parent.children, &xmlNode{name: name, text: stringifyValue(data, cf, r.opts)})
+			}
+			continue
+		}
+		if cf.omitEmpty && isEmptyField(src, cf) {
+			continue
+		}
+		leaf := parent.child(name)
+		leaf.text = stringifyField(src, cf, r.opts)
+	}
+	return record
+}
+
+// isEmptyField returns whether the given column's value is empty (or absent),
+// per omitempty.
+func isEmptyField(src reflect.Value, cf *columnField) bool {
+	if cf.wildcard {
+		return len(fieldValues(src, cf)) == 0
+	}
+	data, ok := fieldValue(src, cf)
+	return !ok || isEmptyValue(data)
+}
+
+// xmlNode is an element of the XML output, with its attributes and children in
+// insertion (column) order.
+type xmlNode struct {
+	name     string
+	attrs    []xmlAttr
+	text     string
+	children []*xmlNode
+}
+
+type xmlAttr struct {
+	name, value string
+}
+
+// child returns the last child element of the given name, appending one if
+// there is none.
+func (n *xmlNode) child(name string) *xmlNode {
+	for i := len(n.children) - 1; i >= 0; i-- {
+		if n.children[i].name == name {
+			return n.children[i]
+		}
+	}
+	c := &xmlNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// descend returns the element at the given path beneath n, creating any
+// elements along it that do not exist, as gabs.SetP does for objects.
+func (n *xmlNode) descend(path []string) *xmlNode {
+	for _, name := range path {
+		n = n.child(name)
+	}
+	return n
+}
+
+// write writes the element, and its descendants, to sb.
+func (n *xmlNode) write(sb *strings.Builder) {
+	sb.WriteString("<" + n.name)
+	for _, a := range n.attrs {
+		sb.WriteString(" " + a.name + `="`)
+		xml.EscapeText(sb, []byte(a.value))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString(">")
+	xml.EscapeText(sb, []byte(n.text))
+	for _, c := range n.children {
+		c.write(sb)
+	}
+	sb.WriteString("</" + n.name + ">")
+}
+
+// isXMLName returns whether the given name is a valid (unprefixed) XML element
+// or attribute name.
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.' || unicode.Is(unicode.Mn, r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,207 @@
+package weave
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToXML(t *testing.T) {
+	type geo struct {
+		Country string
+		City    string
+	}
+	type src struct {
+		IP  string
+		Geo *geo
+	}
+	type rec struct {
+		ID   int
+		Name string `xml:"name,omitempty"`
+		Src  src
+		Tags []string
+	}
+	data := []*rec{
+		{ID: 1, Name: `<a & "b">`, Src: src{IP: "10.0.0.1", Geo: &geo{Country: "NZ", City: "Wellington"}}, Tags: []string{"x", "y"}},
+		{ID: 2, Src: src{IP: "10.0.0.2"}},
+	}
+
+	t.Run("superfluous", func(t *testing.T) {
+		actual, err := ToXML[any](nil, []string{"A"})
+		if err != nil {
+			t.Error("Expected no error, got: ", err)
+		}
+		if want := xml.Header + "<records></records>"; actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("not a struct", func(t *testing.T) {
+		if _, err := ToXML([]int{1}, []string{"A"}); !errors.Is(err, ErrNotAStruct) {
+			t.Errorf("expected '%v', got '%v'", ErrNotAStruct, err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		data    []*rec
+		columns []Column
+		opts    []Option
+		want    string
+	}{
+		{
+			name:    "nested",
+			data:    data,
+			columns: toColumns([]string{"ID", "Src.IP", "Src.Geo.Country", "Name"}),
+			want: "<records>\n" +
+				"<record><ID>1</ID><Src><IP>10.0.0.1</IP><Geo><Country>NZ</Country></Geo></Src><Name>&lt;a &amp; &#34;b&#34;&gt;</Name></record>\n" +
+				"<record><ID>2</ID><Src><IP>10.0.0.2</IP><Geo><Country></Country></Geo></Src><Name></Name></record>\n" +
+				"</records>",
+		},
+		{
+			name:    "element names",
+			data:    data[:1],
+			columns: toColumns([]string{"ID"}),
+			opts:    []Option{XMLRoot("events"), XMLRecord("event")},
+			want:    "<events>\n<event><ID>1</ID></event>\n</events>",
+		},
+		{
+			name:    "attributes",
+			data:    data,
+			columns: toColumns([]string{"ID", "Src.IP", "Src.Geo.City", "Name"}),
+			opts:    []Option{XMLAttrs("ID", "Src.IP", "Name")},
+			want: "<records>\n" +
+				"<record ID=\"1\" Name=\"&lt;a &amp; &#34;b&#34;&gt;\"><Src IP=\"10.0.0.1\"><Geo><City>Wellington</City></Geo></Src></record>\n" +
+				"<record ID=\"2\" Name=\"\"><Src IP=\"10.0.0.2\"><Geo><City></City></Geo></Src></record>\n" +
+				"</records>",
+		},
+		{
+			name:    "wildcards repeat",
+			data:    data,
+			columns: []Column{{Path: "ID"}, {Path: "Tags.*", Alias: "Tags.Tag"}},
+			want: "<records>\n" +
+				"<record><ID>1</ID><Tags><Tag>x</Tag><Tag>y</Tag></Tags></record>\n" +
+				"<record><ID>2</ID><Tags></Tags></record>\n" +
+				"</records>",
+		},
+		{
+			name:    "tags and omitempty",
+			data:    data,
+			columns: toColumns([]string{"ID", "name"}),
+			opts:    []Option{Tags("xml"), XMLAttrs("name")},
+			want: "<records>\n" +
+				"<record name=\"&lt;a &amp; &#34;b&#34;&gt;\"><ID>1</ID></record>\n" +
+				"<record><ID>2</ID></record>\n" +
+				"</records>",
+		},
+		{
+			name:    "nil records and unknown columns",
+			data:    []*rec{nil, {ID: 3}},
+			columns: toColumns([]string{"ID", "Missing"}),
+			want: "<records>\n" +
+				"<record></record>\n" +
+				"<record><ID>3</ID></record>\n" +
+				"</records>",
+		},
+		{
+			name:    "exploded",
+			data:    data[:1],
+			columns: toColumns([]string{"ID", "Tags"}),
+			opts:    []Option{Explode("Tags")},
+			want: "<records>\n" +
+				"<record><ID>1</ID><Tags>x</Tags></record>\n" +
+				"<record><ID>1</ID><Tags>y</Tags></record>\n" +
+				"</records>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ToXML(tt.data, tt.columns, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := xml.Header + tt.want; actual != want {
+				t.Errorf("\n---ToXML()---\n'%v'\n---want---\n'%v'", actual, want)
+			}
+		})
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		type out struct {
+			Records []struct {
+				ID   int    `xml:"ID,attr"`
+				Name string `xml:"Name"`
+				Src  struct {
+					IP string `xml:"IP"`
+				} `xml:"Src"`
+			} `xml:"record"`
+		}
+		actual, err := ToXML(data, []string{"ID", "Name", "Src.IP"}, XMLAttrs("ID"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var o out
+		if err := xml.NewDecoder(strings.NewReader(actual)).Decode(&o); err != nil {
+			t.Fatal(err)
+		}
+		if len(o.Records) != 2 || o.Records[0].ID != 1 || o.Records[0].Name != data[0].Name || o.Records[1].Src.IP != "10.0.0.2" {
+			t.Errorf("unexpected decoding of:\n%v\n%+v", actual, o)
+		}
+	})
+
+	t.Run("duplicate names", func(t *testing.T) {
+		for _, tt := range []struct {
+			columns []Column
+			opts    []Option
+		}{
+			{columns: []Column{{Path: "ID"}, {Path: "Src.IP", Alias: "ID"}}, opts: []Option{XMLAttrs("ID", "Src.IP")}},
+			{columns: []Column{{Path: "ID"}, {Path: "Src.IP", Alias: "ID"}}},
+			{columns: []Column{{Path: "Src.IP"}, {Path: "Name", Alias: "Src.IP"}}},
+			{columns: []Column{{Path: "Src.IP"}, {Path: "Name", Alias: "Src.IP"}}, opts: []Option{XMLAttrs("Src.IP", "Name")}},
+			// elements holding both text and the elements of other columns
+			{columns: toColumns([]string{"Src", "Src.IP"})},
+			{columns: toColumns([]string{"Src.IP", "Src"})},
+			{columns: []Column{{Path: "ID", Alias: "Src"}, {Path: "Src.IP"}}},
+		} {
+			if _, err := ToXML(data, tt.columns, tt.opts...); !errors.Is(err, ErrDuplicateXMLName) {
+				t.Errorf("%v: expected '%v', got '%v'", tt.columns, ErrDuplicateXMLName, err)
+			}
+		}
+
+		// attributes and elements may share names
+		actual, err := ToXML(data[:1], []Column{{Path: "ID"}, {Path: "Src.IP", Alias: "ID"}}, XMLAttrs("ID"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := xml.Header + "<records>\n<record ID=\"1\"><ID>10.0.0.1</ID></record>\n</records>"; actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+
+		// as may an element and the attributes nested within it
+		actual, err = ToXML(data[:1], []Column{{Path: "ID", Alias: "Src"}, {Path: "Src.IP"}}, XMLAttrs("Src.IP"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := xml.Header + "<records>\n<record><Src IP=\"10.0.0.1\">1</Src></record>\n</records>"; actual != want {
+			t.Errorf("want <> actual:\nwant: '%v'\nactual: '%v'\n", want, actual)
+		}
+	})
+
+	t.Run("invalid names", func(t *testing.T) {
+		for _, tt := range []struct {
+			columns []Column
+			opts    []Option
+		}{
+			{columns: toColumns([]string{"Tags.*"})},
+			{columns: toColumns([]string{"Tags.0"})},
+			{columns: []Column{{Path: "ID", Alias: "has space"}}},
+			{columns: toColumns([]string{"ID"}), opts: []Option{XMLRoot("<r>")}},
+			{columns: toColumns([]string{"ID"}), opts: []Option{XMLRecord("")}},
+		} {
+			if _, err := ToXML(data, tt.columns, tt.opts...); !errors.Is(err, ErrInvalidXMLName) {
+				t.Errorf("%v: expected '%v', got '%v'", tt.columns, ErrInvalidXMLName, err)
+			}
+		}
+	})
+}